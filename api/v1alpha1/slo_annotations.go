@@ -0,0 +1,106 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+var (
+	templateAction      = regexp.MustCompile(`{{(?s:.*?)}}`)
+	prometheusVariables = regexp.MustCompile(`\$(labels|value|externalLabels|externalURL)\b`)
+	// prometheusFields are the fields of the data of Prometheus alert
+	// templates, {{ .Labels.instance }} is the same as {{ $labels.instance }}
+	prometheusFields = regexp.MustCompile(`(^|[^\w.)\]])\.(Labels|Value|ExternalLabels|ExternalURL)\b`)
+)
+
+// AnnotationTemplateData is the data that spec.annotations are rendered with,
+// for example "{{ .Name }} is burning its {{ .Objective }}% budget" or
+// "{{ range .Windows }}{{ .LongWindow }} {{ end }}"
+// +kubebuilder:object:generate=false
+type AnnotationTemplateData struct {
+	Name      string
+	Namespace string
+	// SLI is errors or latency
+	SLI string
+	// Objective is the availability target of the Slo in percent
+	Objective string
+	// Latency are the latency targets of the Slo
+	Latency  []LatencyTarget
+	Severity string
+	// Windows are the long and short window pairs of the alert
+	Windows []AnnotationWindow
+	// BurnRates are the burn rate multipliers of Windows, in the same order
+	BurnRates []float64
+	// Remaining is the percentage of the error budget left at which an
+	// error-budget alert fires, empty for the other methods
+	Remaining string
+}
+
+// AnnotationWindow is a window of an alert in AnnotationTemplateData
+// +kubebuilder:object:generate=false
+type AnnotationWindow struct {
+	Multiplier  float64
+	LongWindow  string
+	ShortWindow string
+}
+
+// sampleAnnotationData checks the field names used by annotation templates.
+// Every list has an element so that the bodies of range actions run.
+var sampleAnnotationData = AnnotationTemplateData{
+	Name:      "slo",
+	Namespace: "default",
+	SLI:       "errors",
+	Objective: "99.9",
+	Latency:   []LatencyTarget{{LE: "0.1", Target: "95"}},
+	Severity:  "page",
+	Windows:   []AnnotationWindow{{Multiplier: 14.4, LongWindow: "1h", ShortWindow: "5m"}},
+	BurnRates: []float64{14.4},
+	Remaining: "50",
+}
+
+// ParseAnnotationTemplate parses an entry of spec.annotations. Actions that use
+// the variables or fields of Prometheus alert templates, such as {{ $value }},
+// {{ $labels.instance }} or {{ .Labels.instance }}, are turned into string
+// literals so that they are written to the rule unchanged and rendered by
+// Prometheus when the alert fires.
+func ParseAnnotationTemplate(name, text string) (*template.Template, error) {
+	escaped := templateAction.ReplaceAllStringFunc(text, func(action string) string {
+		if !prometheusVariables.MatchString(action) && !prometheusFields.MatchString(strings.Trim(action, "{}-")) {
+			return action
+		}
+		return "{{" + strconv.Quote(action) + "}}"
+	})
+
+	return template.New(name).Option("missingkey=error").Parse(escaped)
+}
+
+// ValidateAnnotationTemplate parses an entry of spec.annotations and executes
+// it with sample data, which catches fields that AnnotationTemplateData does
+// not have.
+func ValidateAnnotationTemplate(name, text string) error {
+	tmpl, err := ParseAnnotationTemplate(name, text)
+	if err != nil {
+		return err
+	}
+
+	var out strings.Builder
+	return tmpl.Execute(&out, sampleAnnotationData)
+}
@@ -17,13 +17,19 @@ limitations under the License.
 package v1alpha1
 
 import (
-	"fmt"
+	"github.com/prometheus/common/model"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"math"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var (
-	DefaultSamples = []sample{
+	// DefaultSamples are the recording groups used when neither the Slo nor the
+	// operator configure samples
+	DefaultSamples = []Sample{
 		{
 			Name:     "short",
 			Interval: "30s",
@@ -42,10 +48,121 @@ var (
 	}
 )
 
-type sample struct {
-	Name     string
-	Interval string
-	Buckets  []string
+// DefaultQuantiles are recorded by a latencyQuantileRecord that does not set quantiles
+var DefaultQuantiles = []string{"0.5", "0.95", "0.99"}
+
+const (
+	DefaultAlertMethod      = "multi-window"
+	BurnRateAlertMethod     = "burn-rate"
+	ErrorBudgetAlertMethod  = "error-budget"
+	ForecastAlertMethod     = "forecast"
+	DefaultObjectivesWindow = "30d"
+)
+
+// DefaultWindows are the multiwindow, multi-burn-rate alerts recommended by the
+// SRE workbook. Spending 2% of a 30d budget in 1h or 5% in 6h pages, spending
+// 10% in 1d or 3d raises a ticket. Their consumption is given for a 30d
+// objectives window, see DefaultWindowsFor.
+var DefaultWindows = []Window{
+	{
+		Duration:     "1h",
+		Consumption:  "2",
+		Notification: "page",
+	},
+	{
+		Duration:     "6h",
+		Consumption:  "5",
+		Notification: "page",
+	},
+	{
+		Duration:     "1d",
+		Consumption:  "10",
+		Notification: "ticket",
+	},
+	{
+		Duration:     "3d",
+		Consumption:  "10",
+		Notification: "ticket",
+	},
+}
+
+// DefaultBurnRateWindows is the window of the burn-rate alert method, it pages
+// when 2% of a 30d budget is spent in 1h
+var DefaultBurnRateWindows = []Window{
+	{
+		Duration:     "1h",
+		Consumption:  "2",
+		Notification: "page",
+	},
+}
+
+// DefaultWindowsFor converts the consumption of default windows, which is given
+// for a 30d objectives window, to objectivesWindow. The windows then alert at
+// the same burn rates, 14.4, 6, 3 and 1 for DefaultWindows, over any objectives
+// window. A consumption above 100% of the budget, for objectives windows
+// shorter than 3d, is capped at 100. An invalid objectives window keeps the 30d
+// consumption, it is reported by validation.
+func DefaultWindowsFor(windows []Window, objectivesWindow string) []Window {
+	scaled := append([]Window(nil), windows...)
+	duration, err := ParseDuration(objectivesWindow)
+	if err != nil || duration <= 0 {
+		return scaled
+	}
+	defaultDuration, _ := ParseDuration(DefaultObjectivesWindow)
+
+	for i := range scaled {
+		consumption, err := strconv.ParseFloat(scaled[i].Consumption, 64)
+		if err != nil {
+			continue
+		}
+		consumption = math.Min(consumption*float64(defaultDuration)/float64(duration), 100)
+		scaled[i].Consumption = strconv.FormatFloat(consumption, 'g', -1, 64)
+	}
+	return scaled
+}
+
+// DefaultBudgetThresholds of the error-budget alert method raise a ticket when
+// half and when three quarters of the budget are spent, and page once it is gone
+var DefaultBudgetThresholds = []BudgetThreshold{
+	{
+		Remaining:    "50",
+		Notification: "ticket",
+	},
+	{
+		Remaining:    "25",
+		Notification: "ticket",
+	},
+	{
+		Remaining:    "0",
+		Notification: "page",
+	},
+}
+
+// DefaultForecast raises a ticket when the burn rate of the last 6h exhausts the
+// error budget within 3d
+var DefaultForecast = Forecast{
+	Horizon:      "3d",
+	Lookback:     "6h",
+	Notification: "ticket",
+}
+
+// Sample is a group of recording rules evaluated at Interval, recording every
+// expression over each of the Buckets windows
+type Sample struct {
+	Name     string   `json:"name"`
+	Interval string   `json:"interval"`
+	Buckets  []string `json:"buckets"`
+	// RecordQuantiles disables the latencyQuantileRecord rules of the group when false
+	// +kubebuilder:validation:Optional
+	RecordQuantiles *bool `json:"recordQuantiles,omitempty"`
+}
+
+func (sample *Sample) GetRecordQuantiles() bool {
+	if sample.RecordQuantiles == nil {
+		return true
+	}
+
+	return *sample.RecordQuantiles
 }
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -72,16 +189,186 @@ type SloSpec struct {
 	Labels map[string]string `json:"labels"`
 	// +kubebuilder:validation:Optional
 	Annotations map[string]string `json:"annotations"`
+	// Samples overrides the operator default recording groups
+	// +kubebuilder:validation:Optional
+	Samples []Sample `json:"samples,omitempty"`
+	// RuleNamespace is the namespace the PrometheusRule is written to, it
+	// overrides the operator default and falls back to the namespace of the Slo
+	// +kubebuilder:validation:Optional
+	RuleNamespace string `json:"ruleNamespace,omitempty"`
+	// RuleLabels are added to the labels of the PrometheusRule, over the
+	// operator default rule labels
+	// +kubebuilder:validation:Optional
+	RuleLabels map[string]string `json:"ruleLabels,omitempty"`
+	// PrometheusRef names the Prometheus that should load the rules. The
+	// PrometheusRule gets the labels its ruleSelector requires.
+	// +kubebuilder:validation:Optional
+	PrometheusRef *PrometheusReference `json:"prometheusRef,omitempty"`
 }
 
 // SloStatus defines the observed state of Slo
 type SloStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// ObservedGeneration is the most recent generation of the Slo seen by the controller
+	// +kubebuilder:validation:Optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +kubebuilder:validation:Optional
+	Conditions []SloCondition `json:"conditions,omitempty"`
+	// PrometheusRule references the PrometheusRule generated for the Slo
+	// +kubebuilder:validation:Optional
+	PrometheusRule *RuleReference `json:"prometheusRule,omitempty"`
+	// RecordingRules lists the names of the recording rules emitted into the PrometheusRule
+	// +kubebuilder:validation:Optional
+	RecordingRules []string `json:"recordingRules,omitempty"`
+	// InvalidRules lists the generated rules that are not valid PromQL, the
+	// PrometheusRule is not written while there are any
+	// +kubebuilder:validation:Optional
+	InvalidRules []InvalidRule `json:"invalidRules,omitempty"`
+
+	// The fields below flatten the spec and the conditions for the printer columns
+
+	// Objective is the availability objective in percent
+	// +kubebuilder:validation:Optional
+	Objective string `json:"objective,omitempty"`
+	// Window is the objectives window
+	// +kubebuilder:validation:Optional
+	Window string `json:"window,omitempty"`
+	// AlertMethods lists the distinct alert methods of the records, comma separated
+	// +kubebuilder:validation:Optional
+	AlertMethods string `json:"alertMethods,omitempty"`
+	// Ready is the status of the Ready condition
+	// +kubebuilder:validation:Optional
+	Ready corev1.ConditionStatus `json:"ready,omitempty"`
+	// RuleName is the name of the generated PrometheusRule
+	// +kubebuilder:validation:Optional
+	RuleName string `json:"ruleName,omitempty"`
+	// BudgetRemaining is the percentage of the error budget of the availability
+	// objective that is left, when it is known
+	// +kubebuilder:validation:Optional
+	BudgetRemaining string `json:"budgetRemaining,omitempty"`
+	// Live holds the current values of the records read from Prometheus, when
+	// the operator is started with a Prometheus URL
+	// +kubebuilder:validation:Optional
+	Live *LiveStatus `json:"live,omitempty"`
+}
+
+// LiveStatus is the current state of the Slo as recorded by Prometheus. The values
+// are empty until Prometheus has evaluated the records.
+type LiveStatus struct {
+	// SLI is the availability over the objectives window, in percent
+	// +kubebuilder:validation:Optional
+	SLI string `json:"sli,omitempty"`
+	// BudgetRemaining is the part of the error budget left over the objectives window, in percent
+	// +kubebuilder:validation:Optional
+	BudgetRemaining string `json:"budgetRemaining,omitempty"`
+	// PageBurnRate is the burn rate over the first page window
+	// +kubebuilder:validation:Optional
+	PageBurnRate string `json:"pageBurnRate,omitempty"`
+	// TicketBurnRate is the burn rate over the first ticket window
+	// +kubebuilder:validation:Optional
+	TicketBurnRate string `json:"ticketBurnRate,omitempty"`
+	// LastQueryTime is when Prometheus was last queried
+	// +kubebuilder:validation:Optional
+	LastQueryTime metav1.Time `json:"lastQueryTime,omitempty"`
+	// LastUpdateTime is when the values were last read successfully
+	// +kubebuilder:validation:Optional
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+	// Error is the error of the last query, if it failed
+	// +kubebuilder:validation:Optional
+	Error string `json:"error,omitempty"`
+}
+
+type SloConditionType string
+
+const (
+	// SloReady is true when the generated PrometheusRule matches the current spec
+	SloReady SloConditionType = "Ready"
+	// SloDegraded is true when the PrometheusRule in the cluster is stale or could not be applied
+	SloDegraded SloConditionType = "Degraded"
+	// SloGenerationFailed is true when no rules could be generated from the current spec
+	SloGenerationFailed SloConditionType = "GenerationFailed"
+	// SloNameConflict is true when another Slo generates records with the same names
+	SloNameConflict SloConditionType = "NameConflict"
+)
+
+type SloCondition struct {
+	Type   SloConditionType       `json:"type"`
+	Status corev1.ConditionStatus `json:"status"`
+	// +kubebuilder:validation:Optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +kubebuilder:validation:Optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// +kubebuilder:validation:Optional
+	Reason string `json:"reason,omitempty"`
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitempty"`
+}
+
+type RuleReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// PrometheusReference references a Prometheus of the Prometheus operator
+type PrometheusReference struct {
+	Name string `json:"name"`
+	// Namespace of the Prometheus, the namespace of the Slo when empty
+	// +kubebuilder:validation:Optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// InvalidRule is a generated rule whose expression failed to parse
+type InvalidRule struct {
+	Group string `json:"group"`
+	// Rule is the record or alert name of the rule
+	Rule  string `json:"rule"`
+	Expr  string `json:"expr"`
+	Error string `json:"error"`
+}
+
+// GetCondition returns the condition of the given type, or nil when it has not been set
+func (status *SloStatus) GetCondition(conditionType SloConditionType) *SloCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == conditionType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// SetCondition adds or replaces the condition of the same type. LastTransitionTime
+// is only moved when the status of the condition changes.
+func (status *SloStatus) SetCondition(condition SloCondition) {
+	existing := status.GetCondition(condition.Type)
+	if existing == nil {
+		if condition.LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		status.Conditions = append(status.Conditions, condition)
+		return
+	}
+
+	if existing.Status == condition.Status {
+		condition.LastTransitionTime = existing.LastTransitionTime
+	} else if condition.LastTransitionTime.IsZero() {
+		condition.LastTransitionTime = metav1.Now()
+	}
+	*existing = condition
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=slo
+// +kubebuilder:printcolumn:name="Objective",type=string,JSONPath=`.status.objective`
+// +kubebuilder:printcolumn:name="Window",type=string,JSONPath=`.status.window`
+// +kubebuilder:printcolumn:name="Alert Methods",type=string,JSONPath=`.status.alertMethods`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.ready`
+// +kubebuilder:printcolumn:name="Rule",type=string,JSONPath=`.status.ruleName`
+// +kubebuilder:printcolumn:name="Budget Remaining",type=string,JSONPath=`.status.budgetRemaining`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:storageversion
 
 // Slo is the Schema for the sloes API
 type Slo struct {
@@ -116,6 +403,18 @@ type ExprBlock struct {
 	ShortWindow *bool `json:"shortWindow"`
 	// +kubebuilder:validation:Optional
 	Buckets []string `json:"buckets"` // used to define buckets of histogram when using latency expression
+	// Quantiles recorded by the latencyQuantileRecord, such as "0.9" or "0.999"
+	// +kubebuilder:validation:Optional
+	Quantiles []string `json:"quantiles,omitempty"`
+	// BudgetThresholds of the error-budget alert method
+	// +kubebuilder:validation:Optional
+	BudgetThresholds []BudgetThreshold `json:"budgetThresholds,omitempty"`
+	// Forecast of the forecast alert method
+	// +kubebuilder:validation:Optional
+	Forecast *Forecast `json:"forecast,omitempty"`
+	// MinRate of the trafficRateRecord, alerts are only raised for windows whose traffic rate is above it
+	// +kubebuilder:validation:Optional
+	MinRate string `json:"minRate,omitempty"`
 	// +kubebuilder:validation:Optional
 	Expr string `json:"expr"`
 }
@@ -126,8 +425,29 @@ type Window struct {
 	Notification string `json:"notification"`
 }
 
+// BudgetThreshold alerts once no more than Remaining percent of the error
+// budget over the objectives window is left
+type BudgetThreshold struct {
+	Remaining    string `json:"remaining"`
+	Notification string `json:"notification"`
+}
+
+// Forecast alerts when the error budget runs out within Horizon at the burn
+// rate over Lookback
+type Forecast struct {
+	// +kubebuilder:validation:Optional
+	Horizon string `json:"horizon,omitempty"`
+	// +kubebuilder:validation:Optional
+	Lookback string `json:"lookback,omitempty"`
+	// +kubebuilder:validation:Optional
+	Notification string `json:"notification,omitempty"`
+}
+
+// GetShortWindow reports whether the alerts also check a short window of 1/12
+// of each long window. It defaults to true, and to false for the burn-rate
+// method, which alerts over a single window.
 func (block *ExprBlock) GetShortWindow() bool {
-	defaultShortWindow := true
+	defaultShortWindow := block.AlertMethod != BurnRateAlertMethod
 
 	if block.ShortWindow == nil {
 		return defaultShortWindow
@@ -140,11 +460,45 @@ func (block *ExprBlock) ComputeExpr(window, le string) string {
 	return replacer.Replace(block.Expr)
 }
 
-func (block *ExprBlock) ComputeQuantile(window string, quantile float64) string {
-	replacer := strings.NewReplacer("$window", window, "$quantile", fmt.Sprintf("%g", quantile))
+// GetQuantiles returns the configured quantiles or DefaultQuantiles
+func (block *ExprBlock) GetQuantiles() []string {
+	if len(block.Quantiles) == 0 {
+		return DefaultQuantiles
+	}
+
+	return block.Quantiles
+}
+
+// QuantileName derives the record name of a quantile, 0.9 is recorded as p90
+// and 0.999 as p999
+func QuantileName(quantile string) string {
+	digits := strings.TrimPrefix(quantile, "0.")
+	if len(digits) == 1 {
+		digits += "0"
+	}
+	return "p" + digits
+}
+
+func (block *ExprBlock) ComputeQuantile(window, quantile string) string {
+	replacer := strings.NewReplacer("$window", window, "$quantile", quantile)
 	return replacer.Replace(block.Expr)
 }
 
+// ParseDuration parses an objectives window. Prometheus durations such as 30d
+// are preferred, Go durations such as 720h are accepted as well.
+func ParseDuration(duration string) (time.Duration, error) {
+	promDuration, err := model.ParseDuration(duration)
+	if err == nil {
+		return time.Duration(promDuration), nil
+	}
+
+	goDuration, goErr := time.ParseDuration(duration)
+	if goErr != nil {
+		return 0, err
+	}
+	return goDuration, nil
+}
+
 type Objectives struct {
 	Availability string          `json:"availability"`
 	Latency      []LatencyTarget `json:"latency"`
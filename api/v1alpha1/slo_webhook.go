@@ -0,0 +1,479 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var slolog = logf.Log.WithName("slo-resource")
+
+// alertMethodRegistered reports whether an alert method is known to the rule
+// generator. The generator lives in pkg/slo, which imports this package, so the
+// lookup is handed in by SetupWebhookWithManager.
+var alertMethodRegistered = func(string) bool { return true }
+
+// ruleNamespaceAllowed reports whether a Slo in sloNamespace may write its
+// PrometheusRule to namespace. The allowed namespaces are operator settings,
+// they are handed in by SetupWebhookWithManager as well.
+var ruleNamespaceAllowed = func(sloNamespace, namespace string) bool { return namespace == sloNamespace }
+
+// prometheusRefErrors checks spec.prometheusRef against the Prometheus it
+// references, which is read by the controller, so the check is handed in by
+// SetupWebhookWithManager too.
+var prometheusRefErrors = func(*Slo) field.ErrorList { return nil }
+
+func (r *Slo) SetupWebhookWithManager(mgr ctrl.Manager, isAlertMethod func(name string) bool, isRuleNamespaceAllowed func(sloNamespace, namespace string) bool, validatePrometheusRef func(slo *Slo) field.ErrorList) error {
+	alertMethodRegistered = isAlertMethod
+	ruleNamespaceAllowed = isRuleNamespaceAllowed
+	prometheusRefErrors = validatePrometheusRef
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-monitoring-kanzifucius-com-v1alpha1-slo,mutating=true,failurePolicy=fail,groups=monitoring.kanzifucius.com,resources=sloes,verbs=create;update,versions=v1alpha1,name=mslo.kb.io
+
+var _ webhook.Defaulter = &Slo{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *Slo) Default() {
+	slolog.Info("default", "name", r.Name)
+
+	if r.Spec.Objectives.Window == "" {
+		r.Spec.Objectives.Window = DefaultObjectivesWindow
+	}
+
+	r.Spec.ErrorRateRecord.defaultAlerting(r.Spec.Objectives.Window)
+	r.Spec.LatencyRecord.defaultAlerting(r.Spec.Objectives.Window)
+}
+
+// defaultAlerting fills in the alert method and windows of a block that records
+// an expression, so the effective alerting configuration is visible on the object.
+// The default multi-window windows always check their short windows, as they
+// did before they were defaulted.
+func (block *ExprBlock) defaultAlerting(objectivesWindow string) {
+	if block.Expr == "" {
+		return
+	}
+
+	if block.AlertMethod == "" {
+		block.AlertMethod = DefaultAlertMethod
+	}
+
+	if len(block.Windows) == 0 {
+		switch block.AlertMethod {
+		case DefaultAlertMethod:
+			block.Windows = DefaultWindowsFor(DefaultWindows, objectivesWindow)
+			shortWindow := true
+			block.ShortWindow = &shortWindow
+		case BurnRateAlertMethod:
+			block.Windows = DefaultWindowsFor(DefaultBurnRateWindows, objectivesWindow)
+		}
+	}
+
+	if block.AlertMethod == ErrorBudgetAlertMethod && len(block.BudgetThresholds) == 0 {
+		block.BudgetThresholds = append([]BudgetThreshold(nil), DefaultBudgetThresholds...)
+	}
+
+	if block.AlertMethod == ForecastAlertMethod {
+		if block.Forecast == nil {
+			block.Forecast = &Forecast{}
+		}
+		if block.Forecast.Horizon == "" {
+			block.Forecast.Horizon = DefaultForecast.Horizon
+		}
+		if block.Forecast.Lookback == "" {
+			block.Forecast.Lookback = DefaultForecast.Lookback
+		}
+		if block.Forecast.Notification == "" {
+			block.Forecast.Notification = DefaultForecast.Notification
+		}
+	}
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-monitoring-kanzifucius-com-v1alpha1-slo,mutating=false,failurePolicy=fail,groups=monitoring.kanzifucius.com,resources=sloes,versions=v1alpha1,name=vslo.kb.io
+
+var _ webhook.Validator = &Slo{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Slo) ValidateCreate() error {
+	slolog.Info("validate create", "name", r.Name)
+
+	return r.validateSlo()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Slo) ValidateUpdate(old runtime.Object) error {
+	slolog.Info("validate update", "name", r.Name)
+
+	// the checks depend on the Prometheus, the namespaces and the operator
+	// flags, which may have changed since the Slo was admitted. Updates that
+	// leave the spec alone, such as adding or removing the finalizer, must not
+	// be blocked by them, or the Slo could never be deleted.
+	if r.DeletionTimestamp != nil {
+		return nil
+	}
+	if oldSlo, ok := old.(*Slo); ok {
+		// Slos stored before the webhook existed only get their defaults here
+		defaulted := oldSlo.DeepCopy()
+		defaulted.Default()
+		if equality.Semantic.DeepEqual(defaulted.Spec, r.Spec) || equality.Semantic.DeepEqual(oldSlo.Spec, r.Spec) {
+			return nil
+		}
+	}
+	return r.validateSlo()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Slo) ValidateDelete() error {
+	return nil
+}
+
+func (r *Slo) validateSlo() error {
+	allErrs := r.Spec.Validate(field.NewPath("spec"))
+	if r.Spec.RuleNamespace != "" && !ruleNamespaceAllowed(r.Namespace, r.Spec.RuleNamespace) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "ruleNamespace"),
+			fmt.Sprintf("the operator does not allow rules in namespace %s, only in the namespace of the Slo, its --rule-namespace and its --allowed-rule-namespaces", r.Spec.RuleNamespace)))
+	}
+	if len(allErrs) == 0 && r.Spec.PrometheusRef != nil {
+		allErrs = prometheusRefErrors(r)
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "Slo"}, r.Name, allErrs)
+}
+
+// Validate checks the values that are otherwise only parsed when rules are generated
+func (spec *SloSpec) Validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	allErrs = append(allErrs, spec.Objectives.validate(path.Child("objectives"))...)
+	allErrs = append(allErrs, spec.TrafficRateRecord.validate(path.Child("trafficRateRecord"))...)
+	allErrs = append(allErrs, spec.ErrorRateRecord.validate(path.Child("errorRateRecord"))...)
+	allErrs = append(allErrs, spec.LatencyRecord.validate(path.Child("latencyRecord"))...)
+	allErrs = append(allErrs, spec.LatencyQuantileRecord.validate(path.Child("latencyQuantileRecord"))...)
+	allErrs = append(allErrs, spec.TrafficRateRecord.validateMinRate(path.Child("trafficRateRecord"))...)
+	allErrs = append(allErrs, validateQuantiles(spec.LatencyQuantileRecord.Quantiles, path.Child("latencyQuantileRecord", "quantiles"))...)
+	allErrs = append(allErrs, ValidateSamples(spec.Samples, path.Child("samples"))...)
+
+	if spec.RuleNamespace != "" {
+		for _, msg := range validation.IsDNS1123Label(spec.RuleNamespace) {
+			allErrs = append(allErrs, field.Invalid(path.Child("ruleNamespace"), spec.RuleNamespace, msg))
+		}
+	}
+	allErrs = append(allErrs, validateRuleLabels(spec.RuleLabels, path.Child("ruleLabels"))...)
+	if spec.PrometheusRef != nil {
+		allErrs = append(allErrs, spec.PrometheusRef.validate(path.Child("prometheusRef"))...)
+	}
+
+	for name, text := range spec.Annotations {
+		if err := ValidateAnnotationTemplate(name, text); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("annotations").Key(name), text, err.Error()))
+		}
+	}
+
+	return allErrs
+}
+
+// validateRuleLabels checks that the labels are valid Kubernetes labels and do
+// not override the labels the operator tracks its rules with
+func validateRuleLabels(ruleLabels map[string]string, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for key, value := range ruleLabels {
+		for _, msg := range validation.IsQualifiedName(key) {
+			allErrs = append(allErrs, field.Invalid(path.Key(key), key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(value) {
+			allErrs = append(allErrs, field.Invalid(path.Key(key), value, msg))
+		}
+		if strings.HasPrefix(key, GroupVersion.Group+"/") {
+			allErrs = append(allErrs, field.Forbidden(path.Key(key), "labels with the "+GroupVersion.Group+"/ prefix are set by the operator"))
+		}
+	}
+	return allErrs
+}
+
+func (ref *PrometheusReference) validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if ref.Name == "" {
+		allErrs = append(allErrs, field.Required(path.Child("name"), "the name of the Prometheus is required"))
+	} else {
+		for _, msg := range validation.IsDNS1123Subdomain(ref.Name) {
+			allErrs = append(allErrs, field.Invalid(path.Child("name"), ref.Name, msg))
+		}
+	}
+	if ref.Namespace != "" {
+		for _, msg := range validation.IsDNS1123Label(ref.Namespace) {
+			allErrs = append(allErrs, field.Invalid(path.Child("namespace"), ref.Namespace, msg))
+		}
+	}
+	return allErrs
+}
+
+func (objectives *Objectives) validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if err := validatePercentage(objectives.Availability, path.Child("availability")); err != nil {
+		allErrs = append(allErrs, err)
+	}
+
+	for i, latency := range objectives.Latency {
+		if err := validatePercentage(latency.Target, path.Child("latency").Index(i).Child("target")); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+
+	window, err := ParseDuration(objectives.Window)
+	if err != nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("window"), objectives.Window, err.Error()))
+	} else if window <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("window"), objectives.Window, "must be greater than zero"))
+	}
+
+	return allErrs
+}
+
+func (block *ExprBlock) validate(path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if block.AlertMethod != "" && !alertMethodRegistered(block.AlertMethod) {
+		allErrs = append(allErrs, field.Invalid(path.Child("alertMethod"), block.AlertMethod, "is not a registered alert method"))
+	}
+
+	if block.BurnRate != "" {
+		burnRate, err := strconv.ParseFloat(block.BurnRate, 64)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("burnRate"), block.BurnRate, "must be a number"))
+		} else if burnRate <= 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("burnRate"), block.BurnRate, "must be greater than zero"))
+		}
+	}
+
+	if block.AlertMethod == BurnRateAlertMethod && len(block.Windows) > 1 {
+		allErrs = append(allErrs, field.TooMany(path.Child("windows"), len(block.Windows), 1))
+	}
+
+	remaining := map[float64]bool{}
+	for i, threshold := range block.BudgetThresholds {
+		thresholdPath := path.Child("budgetThresholds").Index(i)
+
+		value, err := strconv.ParseFloat(threshold.Remaining, 64)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(thresholdPath.Child("remaining"), threshold.Remaining, "must be a number"))
+		} else if value < 0 || value >= 100 {
+			allErrs = append(allErrs, field.Invalid(thresholdPath.Child("remaining"), threshold.Remaining, "must be at least 0 and less than 100"))
+		} else if remaining[value] {
+			allErrs = append(allErrs, field.Duplicate(thresholdPath.Child("remaining"), threshold.Remaining))
+		}
+		remaining[value] = true
+
+		if err := validateNotification(threshold.Notification, thresholdPath.Child("notification")); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+
+	if forecast := block.Forecast; forecast != nil {
+		forecastPath := path.Child("forecast")
+		if forecast.Horizon != "" {
+			if err := validatePromDuration(forecast.Horizon, forecastPath.Child("horizon")); err != nil {
+				allErrs = append(allErrs, err)
+			}
+		}
+		if forecast.Lookback != "" {
+			if err := validatePromDuration(forecast.Lookback, forecastPath.Child("lookback")); err != nil {
+				allErrs = append(allErrs, err)
+			}
+		}
+		if forecast.Notification != "" {
+			if err := validateNotification(forecast.Notification, forecastPath.Child("notification")); err != nil {
+				allErrs = append(allErrs, err)
+			}
+		}
+	}
+
+	if block.Expr != "" && !strings.Contains(block.Expr, "$window") {
+		allErrs = append(allErrs, field.Invalid(path.Child("expr"), block.Expr, "must contain the $window placeholder"))
+	}
+
+	for i, window := range block.Windows {
+		windowPath := path.Child("windows").Index(i)
+
+		if err := validatePromDuration(window.Duration, windowPath.Child("duration")); err != nil {
+			allErrs = append(allErrs, err)
+		}
+
+		consumption, err := strconv.ParseFloat(window.Consumption, 64)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(windowPath.Child("consumption"), window.Consumption, "must be a number"))
+		} else if consumption <= 0 || consumption > 100 {
+			allErrs = append(allErrs, field.Invalid(windowPath.Child("consumption"), window.Consumption, "must be greater than 0 and at most 100"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateMinRate checks that the traffic guard is a non-negative number and
+// that the traffic it compares with is recorded
+func (block *ExprBlock) validateMinRate(path *field.Path) field.ErrorList {
+	if block.MinRate == "" {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+	minRate, err := strconv.ParseFloat(block.MinRate, 64)
+	if err != nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("minRate"), block.MinRate, "must be a number"))
+	} else if minRate < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("minRate"), block.MinRate, "must not be negative"))
+	}
+	if block.Expr == "" {
+		allErrs = append(allErrs, field.Required(path.Child("expr"), "is required when minRate is set"))
+	}
+
+	return allErrs
+}
+
+// validateQuantiles checks that every quantile is a number strictly between 0
+// and 1 and that no two quantiles share a record name
+func validateQuantiles(quantiles []string, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	names := map[string]bool{}
+	for i, quantile := range quantiles {
+		value, err := strconv.ParseFloat(quantile, 64)
+		if err != nil || !strings.HasPrefix(quantile, "0.") {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), quantile, "must be a decimal such as 0.95"))
+			continue
+		}
+		if value <= 0 || value >= 1 {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), quantile, "must be greater than 0 and less than 1"))
+			continue
+		}
+
+		name := QuantileName(quantile)
+		if names[name] {
+			allErrs = append(allErrs, field.Duplicate(path.Index(i), quantile))
+		}
+		names[name] = true
+	}
+
+	return allErrs
+}
+
+// reservedSampleNames name rule groups that the operator generates next to the
+// sample groups, slo:<name>:metadata and slo:<name>:alert. Samples ending in
+// :alerting are reserved for the windows read by alerts that no sample records.
+var reservedSampleNames = []string{"metadata", "alert"}
+
+// ValidateSamples checks that sample names are unique and not reserved, and
+// that every interval and bucket is a positive Prometheus duration
+func ValidateSamples(samples []Sample, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	names := map[string]bool{}
+	for i, sample := range samples {
+		samplePath := path.Index(i)
+
+		if sample.Name == "" {
+			allErrs = append(allErrs, field.Required(samplePath.Child("name"), ""))
+		} else if names[sample.Name] {
+			allErrs = append(allErrs, field.Duplicate(samplePath.Child("name"), sample.Name))
+		} else if isReservedSampleName(sample.Name) {
+			allErrs = append(allErrs, field.Invalid(samplePath.Child("name"), sample.Name,
+				fmt.Sprintf("is reserved for a generated rule group, names must not be %s or end in :alerting", strings.Join(reservedSampleNames, " or "))))
+		}
+		names[sample.Name] = true
+
+		if err := validatePromDuration(sample.Interval, samplePath.Child("interval")); err != nil {
+			allErrs = append(allErrs, err)
+		}
+
+		if len(sample.Buckets) == 0 {
+			allErrs = append(allErrs, field.Required(samplePath.Child("buckets"), ""))
+		}
+		for j, bucket := range sample.Buckets {
+			if err := validatePromDuration(bucket, samplePath.Child("buckets").Index(j)); err != nil {
+				allErrs = append(allErrs, err)
+			}
+		}
+	}
+
+	return allErrs
+}
+
+func isReservedSampleName(name string) bool {
+	if strings.HasSuffix(name, ":alerting") {
+		return true
+	}
+	for _, reserved := range reservedSampleNames {
+		if name == reserved {
+			return true
+		}
+	}
+	return false
+}
+
+// validatePromDuration checks that value is a positive duration as used in
+// PromQL range selectors, such as 5m or 28d
+func validatePromDuration(value string, path *field.Path) *field.Error {
+	duration, err := model.ParseDuration(value)
+	if err != nil {
+		return field.Invalid(path, value, err.Error())
+	}
+	if duration <= 0 {
+		return field.Invalid(path, value, "must be greater than zero")
+	}
+	return nil
+}
+
+// validateNotification checks that value is one of the alert severities
+func validateNotification(value string, path *field.Path) *field.Error {
+	if value != "page" && value != "ticket" {
+		return field.NotSupported(path, value, []string{"page", "ticket"})
+	}
+	return nil
+}
+
+// validatePercentage checks that value is a number strictly between 0 and 100
+func validatePercentage(value string, path *field.Path) *field.Error {
+	percentage, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return field.Invalid(path, value, "must be a number")
+	}
+	if percentage <= 0 || percentage >= 100 {
+		return field.Invalid(path, value, "must be greater than 0 and less than 100")
+	}
+	return nil
+}
@@ -0,0 +1,300 @@
+package v1alpha1
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func validSlo() *Slo {
+	return &Slo{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-service",
+			Namespace: "test-ns",
+		},
+		Spec: SloSpec{
+			Objectives: Objectives{
+				Availability: "99.9",
+				Latency: []LatencyTarget{{
+					LE:     "0.1",
+					Target: "95",
+				}},
+				Window: "30d",
+			},
+			ErrorRateRecord: ExprBlock{
+				AlertMethod: "multi-window",
+				Windows: []Window{{
+					Duration:     "1h",
+					Consumption:  "2",
+					Notification: "page",
+				}},
+				Expr: "sum(rate(http_requests_total{status=\"5xx\"}[$window])) / sum(rate(http_requests_total[$window]))",
+			},
+		},
+	}
+}
+
+func TestValidateCreateAcceptsValidSlo(t *testing.T) {
+	assert.NoError(t, validSlo().ValidateCreate())
+}
+
+func TestValidateCreateRejectsInvalidSpec(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*Slo)
+		field  string
+	}{
+		{"availability not a number", func(s *Slo) { s.Spec.Objectives.Availability = "high" }, "spec.objectives.availability"},
+		{"availability of 100", func(s *Slo) { s.Spec.Objectives.Availability = "100" }, "spec.objectives.availability"},
+		{"latency target of 0", func(s *Slo) { s.Spec.Objectives.Latency[0].Target = "0" }, "spec.objectives.latency[0].target"},
+		{"unparsable window", func(s *Slo) { s.Spec.Objectives.Window = "a month" }, "spec.objectives.window"},
+		{"zero window", func(s *Slo) { s.Spec.Objectives.Window = "0" }, "spec.objectives.window"},
+		{"unparsable window duration", func(s *Slo) { s.Spec.ErrorRateRecord.Windows[0].Duration = "1 hour" }, "spec.errorRateRecord.windows[0].duration"},
+		{"consumption above 100", func(s *Slo) { s.Spec.ErrorRateRecord.Windows[0].Consumption = "120" }, "spec.errorRateRecord.windows[0].consumption"},
+		{"unknown alert method", func(s *Slo) { s.Spec.ErrorRateRecord.AlertMethod = "single-window" }, "spec.errorRateRecord.alertMethod"},
+		{"expr without $window", func(s *Slo) { s.Spec.ErrorRateRecord.Expr = "sum(rate(http_requests_total[5m]))" }, "spec.errorRateRecord.expr"},
+		{"quantile of 1", func(s *Slo) { s.Spec.LatencyQuantileRecord.Quantiles = []string{"1"} }, "spec.latencyQuantileRecord.quantiles[0]"},
+		{"quantiles with the same name", func(s *Slo) { s.Spec.LatencyQuantileRecord.Quantiles = []string{"0.9", "0.90"} }, "spec.latencyQuantileRecord.quantiles[1]"},
+		{"unparsable annotation", func(s *Slo) { s.Spec.Annotations = map[string]string{"summary": "{{ .Name "} }, "spec.annotations[summary]"},
+		{"annotation with an unknown field", func(s *Slo) { s.Spec.Annotations = map[string]string{"summary": "{{ .Unknown }}"} }, "spec.annotations[summary]"},
+		{"annotation with an unknown field in a range", func(s *Slo) {
+			s.Spec.Annotations = map[string]string{"summary": "{{ range .Windows }}{{ .Duration }}{{ end }}"}
+		}, "spec.annotations[summary]"},
+		{"negative minRate", func(s *Slo) {
+			s.Spec.TrafficRateRecord = ExprBlock{Expr: "sum(rate(http_requests_total[$window]))", MinRate: "-1"}
+		}, "spec.trafficRateRecord.minRate"},
+		{"burn rate of 0", func(s *Slo) { s.Spec.ErrorRateRecord.BurnRate = "0" }, "spec.errorRateRecord.burnRate"},
+		{"burn-rate with two windows", func(s *Slo) {
+			s.Spec.ErrorRateRecord.AlertMethod = BurnRateAlertMethod
+			s.Spec.ErrorRateRecord.Windows = append(s.Spec.ErrorRateRecord.Windows, s.Spec.ErrorRateRecord.Windows[0])
+		}, "spec.errorRateRecord.windows"},
+		{"budget threshold of 100", func(s *Slo) {
+			s.Spec.ErrorRateRecord.BudgetThresholds = []BudgetThreshold{{Remaining: "100", Notification: "ticket"}}
+		}, "spec.errorRateRecord.budgetThresholds[0].remaining"},
+		{"budget threshold with unknown notification", func(s *Slo) {
+			s.Spec.ErrorRateRecord.BudgetThresholds = []BudgetThreshold{{Remaining: "50", Notification: "email"}}
+		}, "spec.errorRateRecord.budgetThresholds[0].notification"},
+		{"unparsable forecast horizon", func(s *Slo) {
+			s.Spec.ErrorRateRecord.Forecast = &Forecast{Horizon: "3 days"}
+		}, "spec.errorRateRecord.forecast.horizon"},
+		{"minRate without traffic", func(s *Slo) { s.Spec.TrafficRateRecord.MinRate = "1" }, "spec.trafficRateRecord.expr"},
+		{"rule namespace not a DNS label", func(s *Slo) { s.Spec.RuleNamespace = "Monitoring" }, "spec.ruleNamespace"},
+		{"rule label with an invalid value", func(s *Slo) { s.Spec.RuleLabels = map[string]string{"release": "prom operator"} }, "spec.ruleLabels[release]"},
+		{"rule label with the operator prefix", func(s *Slo) {
+			s.Spec.RuleLabels = map[string]string{"monitoring.kanzifucius.com/slo-name": "other"}
+		}, "spec.ruleLabels[monitoring.kanzifucius.com/slo-name]"},
+		{"prometheusRef without name", func(s *Slo) { s.Spec.PrometheusRef = &PrometheusReference{Namespace: "monitoring"} }, "spec.prometheusRef.name"},
+		{"sample named like the metadata group", func(s *Slo) {
+			s.Spec.Samples = []Sample{{Name: "metadata", Interval: "1m", Buckets: []string{"5m"}}}
+		}, "spec.samples[0].name"},
+		{"sample named like the alert group", func(s *Slo) {
+			s.Spec.Samples = []Sample{{Name: "alert", Interval: "1m", Buckets: []string{"5m"}}}
+		}, "spec.samples[0].name"},
+		{"sample named like an alerting group", func(s *Slo) {
+			s.Spec.Samples = []Sample{{Name: "daily:alerting", Interval: "1m", Buckets: []string{"5m"}}}
+		}, "spec.samples[0].name"},
+	}
+
+	alertMethodRegistered = func(name string) bool { return name == DefaultAlertMethod || name == BurnRateAlertMethod }
+	defer func() { alertMethodRegistered = func(string) bool { return true } }()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			slo := validSlo()
+			test.mutate(slo)
+
+			errs := slo.Spec.Validate(field.NewPath("spec"))
+			if assert.Len(t, errs, 1) {
+				assert.Equal(t, test.field, errs[0].Field)
+			}
+			assert.Error(t, slo.ValidateCreate())
+		})
+	}
+}
+
+func TestValidateCreateRestrictsRuleNamespace(t *testing.T) {
+	slo := validSlo()
+	slo.Spec.RuleNamespace = "kube-system"
+	err := slo.ValidateCreate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "spec.ruleNamespace: Forbidden")
+	}
+
+	slo.Spec.RuleNamespace = slo.Namespace
+	assert.NoError(t, slo.ValidateCreate())
+
+	ruleNamespaceAllowed = func(sloNamespace, namespace string) bool { return namespace == "monitoring" }
+	defer func() {
+		ruleNamespaceAllowed = func(sloNamespace, namespace string) bool { return namespace == sloNamespace }
+	}()
+	slo.Spec.RuleNamespace = "monitoring"
+	assert.NoError(t, slo.ValidateCreate())
+	slo.Spec.RuleNamespace = "kube-system"
+	assert.Error(t, slo.ValidateCreate())
+}
+
+func TestValidateCreateChecksPrometheusRef(t *testing.T) {
+	var checked []*Slo
+	prometheusRefErrors = func(slo *Slo) field.ErrorList {
+		checked = append(checked, slo)
+		return field.ErrorList{field.Forbidden(field.NewPath("spec", "ruleNamespace"), "not loaded")}
+	}
+	defer func() { prometheusRefErrors = func(*Slo) field.ErrorList { return nil } }()
+
+	slo := validSlo()
+	assert.NoError(t, slo.ValidateCreate())
+	assert.Empty(t, checked, "a Slo without prometheusRef is not checked against a Prometheus")
+
+	slo.Spec.PrometheusRef = &PrometheusReference{Name: "k8s", Namespace: "monitoring"}
+	err := slo.ValidateCreate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "spec.ruleNamespace: Forbidden: not loaded")
+	}
+	assert.Len(t, checked, 1)
+
+	slo.Spec.PrometheusRef = &PrometheusReference{Namespace: "monitoring"}
+	assert.Error(t, slo.ValidateCreate())
+	assert.Len(t, checked, 1, "an invalid prometheusRef is not looked up")
+}
+
+func TestValidateUpdateSkipsUnchangedSpec(t *testing.T) {
+	prometheusRefErrors = func(*Slo) field.ErrorList {
+		return field.ErrorList{field.Forbidden(field.NewPath("spec", "ruleNamespace"), "not loaded")}
+	}
+	defer func() { prometheusRefErrors = func(*Slo) field.ErrorList { return nil } }()
+
+	old := validSlo()
+	old.Spec.PrometheusRef = &PrometheusReference{Name: "k8s", Namespace: "monitoring"}
+	old.Default()
+
+	// the Prometheus changed after the Slo was admitted
+	withFinalizer := old.DeepCopy()
+	withFinalizer.Finalizers = []string{"slo.monitoring.kanzifucius.com"}
+	assert.NoError(t, withFinalizer.ValidateUpdate(old), "a metadata-only update is not validated")
+
+	deleted := withFinalizer.DeepCopy()
+	deleted.Finalizers = nil
+	deleted.DeletionTimestamp = &metav1.Time{}
+	deleted.Spec.RuleNamespace = "kube-system"
+	assert.NoError(t, deleted.ValidateUpdate(withFinalizer), "a Slo being deleted is not validated")
+
+	changed := withFinalizer.DeepCopy()
+	changed.Spec.Objectives.Availability = "99.5"
+	assert.Error(t, changed.ValidateUpdate(withFinalizer), "a spec change is validated")
+
+	// stored before the webhook existed, without defaults and with a spec that is invalid today
+	stored := validSlo()
+	stored.Spec.ErrorRateRecord.Expr = "sum(rate(http_requests_total[5m]))"
+	stored.Spec.ErrorRateRecord.AlertMethod = ""
+	updated := stored.DeepCopy()
+	updated.Default()
+	updated.Finalizers = []string{"slo.monitoring.kanzifucius.com"}
+	assert.NoError(t, updated.ValidateUpdate(stored), "defaults added by the webhook are not a spec change")
+	updated.Spec.Objectives.Availability = "99.5"
+	assert.Error(t, updated.ValidateUpdate(stored))
+}
+
+func TestValidateCreateAcceptsAnnotationTemplates(t *testing.T) {
+	annotations := map[string]string{
+		"summary":     "{{ .Name }} in {{ .Namespace }} is burning its {{ .Objective }}% {{ .SLI }} budget",
+		"windows":     "{{ range $i, $w := .Windows }}{{ $w.LongWindow }} at {{ index $.BurnRates $i }}x {{ end }}",
+		"latency":     "{{ range .Latency }}{{ .Target }}% under {{ .LE }}s {{ end }}",
+		"value":       "{{ $value | humanizePercentage }} on {{ $labels.instance }}",
+		"fields":      "{{ .Value }} on {{ .Labels.instance }} of {{ .ExternalLabels.cluster }}",
+		"external":    "{{ $externalURL }}",
+		"runbook_url": "https://runbooks.example.com/{{ .Severity }}",
+	}
+	for name, text := range annotations {
+		s := validSlo()
+		s.Spec.Annotations = map[string]string{name: text}
+		assert.NoError(t, s.ValidateCreate(), name)
+	}
+}
+
+func TestParseAnnotationTemplateKeepsPrometheusTemplates(t *testing.T) {
+	tmpl, err := ParseAnnotationTemplate("description", "{{ .Name }}: {{ .Labels.instance }} {{ $value }} {{- .Value -}}")
+	assert.NoError(t, err)
+
+	var out strings.Builder
+	assert.NoError(t, tmpl.Execute(&out, sampleAnnotationData))
+	assert.Equal(t, "slo: {{ .Labels.instance }} {{ $value }} {{- .Value -}}", out.String())
+}
+
+func TestDefaultFillsAlertingConfiguration(t *testing.T) {
+	slo := validSlo()
+	slo.Spec.Objectives.Window = ""
+	slo.Spec.ErrorRateRecord.AlertMethod = ""
+	slo.Spec.ErrorRateRecord.Windows = nil
+	slo.Spec.LatencyRecord.Expr = "sum(rate(http_request_duration_seconds_bucket{le=\"$le\"}[$window]))"
+
+	slo.Default()
+
+	assert.Equal(t, DefaultObjectivesWindow, slo.Spec.Objectives.Window)
+	assert.Equal(t, DefaultAlertMethod, slo.Spec.ErrorRateRecord.AlertMethod)
+	assert.Equal(t, DefaultWindows, slo.Spec.ErrorRateRecord.Windows)
+	assert.Equal(t, DefaultAlertMethod, slo.Spec.LatencyRecord.AlertMethod)
+	assert.Equal(t, DefaultWindows, slo.Spec.LatencyRecord.Windows)
+	assert.Empty(t, slo.Spec.TrafficRateRecord.AlertMethod, "blocks without an expression should not be defaulted")
+	assert.NoError(t, slo.ValidateCreate())
+}
+
+func TestDefaultWindowsFollowObjectivesWindow(t *testing.T) {
+	slo := validSlo()
+	slo.Spec.Objectives.Window = "28d"
+	slo.Spec.ErrorRateRecord.Windows = nil
+
+	slo.Default()
+
+	consumptions := []string{}
+	for _, window := range slo.Spec.ErrorRateRecord.Windows {
+		consumptions = append(consumptions, window.Consumption)
+	}
+	assert.Equal(t, []string{"2.142857142857143", "5.357142857142857", "10.714285714285714", "10.714285714285714"}, consumptions)
+	assert.True(t, slo.Spec.ErrorRateRecord.GetShortWindow())
+	assert.NoError(t, slo.ValidateCreate())
+
+	assert.Equal(t, "100", DefaultWindowsFor(DefaultWindows, "1d")[2].Consumption, "more than the whole budget is capped")
+	assert.Equal(t, DefaultWindows, DefaultWindowsFor(DefaultWindows, "invalid"))
+}
+
+func TestDefaultKeepsUserWindows(t *testing.T) {
+	slo := validSlo()
+	windows := slo.Spec.ErrorRateRecord.Windows
+
+	slo.Default()
+
+	assert.Equal(t, windows, slo.Spec.ErrorRateRecord.Windows)
+}
+
+func TestDefaultBurnRateWindow(t *testing.T) {
+	slo := validSlo()
+	slo.Spec.ErrorRateRecord.AlertMethod = BurnRateAlertMethod
+	slo.Spec.ErrorRateRecord.Windows = nil
+
+	slo.Default()
+
+	assert.Equal(t, DefaultBurnRateWindows, slo.Spec.ErrorRateRecord.Windows)
+	assert.NoError(t, slo.ValidateCreate())
+}
+
+func TestDefaultBudgetThresholds(t *testing.T) {
+	slo := validSlo()
+	slo.Spec.ErrorRateRecord.AlertMethod = ErrorBudgetAlertMethod
+
+	slo.Default()
+
+	assert.Equal(t, DefaultBudgetThresholds, slo.Spec.ErrorRateRecord.BudgetThresholds)
+}
+
+func TestDefaultForecast(t *testing.T) {
+	slo := validSlo()
+	slo.Spec.ErrorRateRecord.AlertMethod = ForecastAlertMethod
+	slo.Spec.ErrorRateRecord.Forecast = &Forecast{Horizon: "7d"}
+
+	slo.Default()
+
+	assert.Equal(t, &Forecast{Horizon: "7d", Lookback: DefaultForecast.Lookback, Notification: DefaultForecast.Notification}, slo.Spec.ErrorRateRecord.Forecast)
+}
@@ -1,3 +1,4 @@
+//go:build !ignore_autogenerated
 // +build !ignore_autogenerated
 
 /*
@@ -21,9 +22,24 @@ limitations under the License.
 package v1alpha1
 
 import (
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BudgetThreshold) DeepCopyInto(out *BudgetThreshold) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BudgetThreshold.
+func (in *BudgetThreshold) DeepCopy() *BudgetThreshold {
+	if in == nil {
+		return nil
+	}
+	out := new(BudgetThreshold)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExprBlock) DeepCopyInto(out *ExprBlock) {
 	*out = *in
@@ -42,6 +58,21 @@ func (in *ExprBlock) DeepCopyInto(out *ExprBlock) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Quantiles != nil {
+		in, out := &in.Quantiles, &out.Quantiles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BudgetThresholds != nil {
+		in, out := &in.BudgetThresholds, &out.BudgetThresholds
+		*out = make([]BudgetThreshold, len(*in))
+		copy(*out, *in)
+	}
+	if in.Forecast != nil {
+		in, out := &in.Forecast, &out.Forecast
+		*out = new(Forecast)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExprBlock.
@@ -54,6 +85,36 @@ func (in *ExprBlock) DeepCopy() *ExprBlock {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Forecast) DeepCopyInto(out *Forecast) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Forecast.
+func (in *Forecast) DeepCopy() *Forecast {
+	if in == nil {
+		return nil
+	}
+	out := new(Forecast)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InvalidRule) DeepCopyInto(out *InvalidRule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InvalidRule.
+func (in *InvalidRule) DeepCopy() *InvalidRule {
+	if in == nil {
+		return nil
+	}
+	out := new(InvalidRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LatencyTarget) DeepCopyInto(out *LatencyTarget) {
 	*out = *in
@@ -69,6 +130,23 @@ func (in *LatencyTarget) DeepCopy() *LatencyTarget {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LiveStatus) DeepCopyInto(out *LiveStatus) {
+	*out = *in
+	in.LastQueryTime.DeepCopyInto(&out.LastQueryTime)
+	in.LastUpdateTime.DeepCopyInto(&out.LastUpdateTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LiveStatus.
+func (in *LiveStatus) DeepCopy() *LiveStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LiveStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Objectives) DeepCopyInto(out *Objectives) {
 	*out = *in
@@ -89,13 +167,68 @@ func (in *Objectives) DeepCopy() *Objectives {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrometheusReference) DeepCopyInto(out *PrometheusReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusReference.
+func (in *PrometheusReference) DeepCopy() *PrometheusReference {
+	if in == nil {
+		return nil
+	}
+	out := new(PrometheusReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleReference) DeepCopyInto(out *RuleReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleReference.
+func (in *RuleReference) DeepCopy() *RuleReference {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Sample) DeepCopyInto(out *Sample) {
+	*out = *in
+	if in.Buckets != nil {
+		in, out := &in.Buckets, &out.Buckets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RecordQuantiles != nil {
+		in, out := &in.RecordQuantiles, &out.RecordQuantiles
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Sample.
+func (in *Sample) DeepCopy() *Sample {
+	if in == nil {
+		return nil
+	}
+	out := new(Sample)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Slo) DeepCopyInto(out *Slo) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Slo.
@@ -116,6 +249,22 @@ func (in *Slo) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SloCondition) DeepCopyInto(out *SloCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SloCondition.
+func (in *SloCondition) DeepCopy() *SloCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(SloCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SloList) DeepCopyInto(out *SloList) {
 	*out = *in
@@ -170,6 +319,25 @@ func (in *SloSpec) DeepCopyInto(out *SloSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.Samples != nil {
+		in, out := &in.Samples, &out.Samples
+		*out = make([]Sample, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RuleLabels != nil {
+		in, out := &in.RuleLabels, &out.RuleLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PrometheusRef != nil {
+		in, out := &in.PrometheusRef, &out.PrometheusRef
+		*out = new(PrometheusReference)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SloSpec.
@@ -185,6 +353,33 @@ func (in *SloSpec) DeepCopy() *SloSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SloStatus) DeepCopyInto(out *SloStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]SloCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PrometheusRule != nil {
+		in, out := &in.PrometheusRule, &out.PrometheusRule
+		*out = new(RuleReference)
+		**out = **in
+	}
+	if in.RecordingRules != nil {
+		in, out := &in.RecordingRules, &out.RecordingRules
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.InvalidRules != nil {
+		in, out := &in.InvalidRules, &out.InvalidRules
+		*out = make([]InvalidRule, len(*in))
+		copy(*out, *in)
+	}
+	if in.Live != nil {
+		in, out := &in.Live, &out.Live
+		*out = new(LiveStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SloStatus.
@@ -0,0 +1,326 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/common/model"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+)
+
+// v1alpha1 is the storage version and conversion hub, v1beta1 converts to and from it.
+var _ conversion.Convertible = &Slo{}
+
+// ConvertTo converts this Slo to the Hub version (v1alpha1).
+func (src *Slo) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1alpha1.Slo)
+
+	dst.ObjectMeta = src.ObjectMeta
+
+	dst.Spec.Objectives = v1alpha1.Objectives{
+		Availability: string(src.Spec.Objectives.Availability),
+		Window:       formatDuration(src.Spec.Objectives.Window),
+	}
+	for _, latency := range src.Spec.Objectives.Latency {
+		dst.Spec.Objectives.Latency = append(dst.Spec.Objectives.Latency, v1alpha1.LatencyTarget{
+			LE:     latency.LE,
+			Target: string(latency.Target),
+		})
+	}
+
+	dst.Spec.TrafficRateRecord = src.Spec.TrafficRateRecord.convertTo()
+	dst.Spec.ErrorRateRecord = src.Spec.ErrorRateRecord.convertTo()
+	dst.Spec.LatencyRecord = src.Spec.LatencyRecord.convertTo()
+	dst.Spec.LatencyQuantileRecord = src.Spec.LatencyQuantileRecord.convertTo()
+	dst.Spec.Labels = src.Spec.Labels
+	dst.Spec.Annotations = src.Spec.Annotations
+	dst.Spec.RuleNamespace = src.Spec.RuleNamespace
+	dst.Spec.RuleLabels = src.Spec.RuleLabels
+	if src.Spec.PrometheusRef != nil {
+		dst.Spec.PrometheusRef = &v1alpha1.PrometheusReference{
+			Name:      src.Spec.PrometheusRef.Name,
+			Namespace: src.Spec.PrometheusRef.Namespace,
+		}
+	}
+	for _, sample := range src.Spec.Samples {
+		dstSample := v1alpha1.Sample{
+			Name:            sample.Name,
+			Interval:        formatDuration(sample.Interval),
+			RecordQuantiles: sample.RecordQuantiles,
+		}
+		for _, bucket := range sample.Buckets {
+			dstSample.Buckets = append(dstSample.Buckets, formatDuration(bucket))
+		}
+		dst.Spec.Samples = append(dst.Spec.Samples, dstSample)
+	}
+
+	dst.Status = v1alpha1.SloStatus{
+		ObservedGeneration: src.Status.ObservedGeneration,
+		RecordingRules:     src.Status.RecordingRules,
+		Objective:          src.Status.Objective,
+		Window:             src.Status.Window,
+		AlertMethods:       src.Status.AlertMethods,
+		Ready:              src.Status.Ready,
+		RuleName:           src.Status.RuleName,
+		BudgetRemaining:    src.Status.BudgetRemaining,
+	}
+	if src.Status.Live != nil {
+		live := v1alpha1.LiveStatus(*src.Status.Live)
+		dst.Status.Live = &live
+	}
+	for _, rule := range src.Status.InvalidRules {
+		dst.Status.InvalidRules = append(dst.Status.InvalidRules, v1alpha1.InvalidRule{
+			Group: rule.Group,
+			Rule:  rule.Rule,
+			Expr:  rule.Expr,
+			Error: rule.Error,
+		})
+	}
+	if src.Status.PrometheusRule != nil {
+		dst.Status.PrometheusRule = &v1alpha1.RuleReference{
+			Name:      src.Status.PrometheusRule.Name,
+			Namespace: src.Status.PrometheusRule.Namespace,
+		}
+	}
+	for _, condition := range src.Status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, v1alpha1.SloCondition{
+			Type:               v1alpha1.SloConditionType(condition.Type),
+			Status:             condition.Status,
+			ObservedGeneration: condition.ObservedGeneration,
+			LastTransitionTime: condition.LastTransitionTime,
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+		})
+	}
+
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1alpha1) to this version.
+func (dst *Slo) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1alpha1.Slo)
+
+	dst.ObjectMeta = src.ObjectMeta
+
+	var err error
+	dst.Spec.Objectives = Objectives{
+		Availability: Percent(src.Spec.Objectives.Availability),
+	}
+	if src.Spec.Objectives.Window != "" {
+		window, err := v1alpha1.ParseDuration(src.Spec.Objectives.Window)
+		if err != nil {
+			return fmt.Errorf("spec.objectives.window: %w", err)
+		}
+		dst.Spec.Objectives.Window = metav1.Duration{Duration: window}
+	}
+	for _, latency := range src.Spec.Objectives.Latency {
+		dst.Spec.Objectives.Latency = append(dst.Spec.Objectives.Latency, LatencyTarget{
+			LE:     latency.LE,
+			Target: Percent(latency.Target),
+		})
+	}
+
+	if dst.Spec.TrafficRateRecord, err = convertFrom(src.Spec.TrafficRateRecord, "spec.trafficRateRecord"); err != nil {
+		return err
+	}
+	if dst.Spec.ErrorRateRecord, err = convertFrom(src.Spec.ErrorRateRecord, "spec.errorRateRecord"); err != nil {
+		return err
+	}
+	if dst.Spec.LatencyRecord, err = convertFrom(src.Spec.LatencyRecord, "spec.latencyRecord"); err != nil {
+		return err
+	}
+	if dst.Spec.LatencyQuantileRecord, err = convertFrom(src.Spec.LatencyQuantileRecord, "spec.latencyQuantileRecord"); err != nil {
+		return err
+	}
+	dst.Spec.Labels = src.Spec.Labels
+	dst.Spec.Annotations = src.Spec.Annotations
+	dst.Spec.RuleNamespace = src.Spec.RuleNamespace
+	dst.Spec.RuleLabels = src.Spec.RuleLabels
+	if src.Spec.PrometheusRef != nil {
+		dst.Spec.PrometheusRef = &PrometheusReference{
+			Name:      src.Spec.PrometheusRef.Name,
+			Namespace: src.Spec.PrometheusRef.Namespace,
+		}
+	}
+	for i, sample := range src.Spec.Samples {
+		interval, err := parsePromDuration(sample.Interval, fmt.Sprintf("spec.samples[%d].interval", i))
+		if err != nil {
+			return err
+		}
+		dstSample := Sample{
+			Name:            sample.Name,
+			Interval:        interval,
+			RecordQuantiles: sample.RecordQuantiles,
+		}
+		for j, bucket := range sample.Buckets {
+			duration, err := parsePromDuration(bucket, fmt.Sprintf("spec.samples[%d].buckets[%d]", i, j))
+			if err != nil {
+				return err
+			}
+			dstSample.Buckets = append(dstSample.Buckets, duration)
+		}
+		dst.Spec.Samples = append(dst.Spec.Samples, dstSample)
+	}
+
+	dst.Status = SloStatus{
+		ObservedGeneration: src.Status.ObservedGeneration,
+		RecordingRules:     src.Status.RecordingRules,
+		Objective:          src.Status.Objective,
+		Window:             src.Status.Window,
+		AlertMethods:       src.Status.AlertMethods,
+		Ready:              src.Status.Ready,
+		RuleName:           src.Status.RuleName,
+		BudgetRemaining:    src.Status.BudgetRemaining,
+	}
+	if src.Status.Live != nil {
+		live := LiveStatus(*src.Status.Live)
+		dst.Status.Live = &live
+	}
+	for _, rule := range src.Status.InvalidRules {
+		dst.Status.InvalidRules = append(dst.Status.InvalidRules, InvalidRule{
+			Group: rule.Group,
+			Rule:  rule.Rule,
+			Expr:  rule.Expr,
+			Error: rule.Error,
+		})
+	}
+	if src.Status.PrometheusRule != nil {
+		dst.Status.PrometheusRule = &RuleReference{
+			Name:      src.Status.PrometheusRule.Name,
+			Namespace: src.Status.PrometheusRule.Namespace,
+		}
+	}
+	for _, condition := range src.Status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, SloCondition{
+			Type:               string(condition.Type),
+			Status:             condition.Status,
+			ObservedGeneration: condition.ObservedGeneration,
+			LastTransitionTime: condition.LastTransitionTime,
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+		})
+	}
+
+	return nil
+}
+
+func (block *ExprBlock) convertTo() v1alpha1.ExprBlock {
+	dst := v1alpha1.ExprBlock{
+		AlertMethod: block.AlertMethod,
+		BurnRate:    string(block.BurnRate),
+		ShortWindow: block.ShortWindow,
+		Buckets:     block.Buckets,
+		MinRate:     string(block.MinRate),
+		Expr:        block.Expr,
+	}
+	for _, quantile := range block.Quantiles {
+		dst.Quantiles = append(dst.Quantiles, string(quantile))
+	}
+	for _, threshold := range block.BudgetThresholds {
+		dst.BudgetThresholds = append(dst.BudgetThresholds, v1alpha1.BudgetThreshold{
+			Remaining:    string(threshold.Remaining),
+			Notification: threshold.Notification,
+		})
+	}
+	if block.Forecast != nil {
+		dst.Forecast = &v1alpha1.Forecast{
+			Horizon:      formatDuration(block.Forecast.Horizon),
+			Lookback:     formatDuration(block.Forecast.Lookback),
+			Notification: block.Forecast.Notification,
+		}
+	}
+	for _, window := range block.Windows {
+		dst.Windows = append(dst.Windows, v1alpha1.Window{
+			Duration:     formatDuration(window.Duration),
+			Consumption:  string(window.Consumption),
+			Notification: window.Notification,
+		})
+	}
+	return dst
+}
+
+func convertFrom(block v1alpha1.ExprBlock, path string) (ExprBlock, error) {
+	dst := ExprBlock{
+		AlertMethod: block.AlertMethod,
+		BurnRate:    Decimal(block.BurnRate),
+		ShortWindow: block.ShortWindow,
+		Buckets:     block.Buckets,
+		MinRate:     Decimal(block.MinRate),
+		Expr:        block.Expr,
+	}
+	for _, quantile := range block.Quantiles {
+		dst.Quantiles = append(dst.Quantiles, Decimal(quantile))
+	}
+	for _, threshold := range block.BudgetThresholds {
+		dst.BudgetThresholds = append(dst.BudgetThresholds, BudgetThreshold{
+			Remaining:    Percent(threshold.Remaining),
+			Notification: threshold.Notification,
+		})
+	}
+	if block.Forecast != nil {
+		dst.Forecast = &Forecast{Notification: block.Forecast.Notification}
+		if block.Forecast.Horizon != "" {
+			horizon, err := parsePromDuration(block.Forecast.Horizon, path+".forecast.horizon")
+			if err != nil {
+				return dst, err
+			}
+			dst.Forecast.Horizon = horizon
+		}
+		if block.Forecast.Lookback != "" {
+			lookback, err := parsePromDuration(block.Forecast.Lookback, path+".forecast.lookback")
+			if err != nil {
+				return dst, err
+			}
+			dst.Forecast.Lookback = lookback
+		}
+	}
+	for i, window := range block.Windows {
+		duration, err := parsePromDuration(window.Duration, fmt.Sprintf("%s.windows[%d].duration", path, i))
+		if err != nil {
+			return dst, err
+		}
+		dst.Windows = append(dst.Windows, Window{
+			Duration:     duration,
+			Consumption:  Percent(window.Consumption),
+			Notification: window.Notification,
+		})
+	}
+	return dst, nil
+}
+
+func parsePromDuration(duration, path string) (metav1.Duration, error) {
+	parsed, err := model.ParseDuration(duration)
+	if err != nil {
+		return metav1.Duration{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return metav1.Duration{Duration: time.Duration(parsed)}, nil
+}
+
+// formatDuration renders a duration the way Prometheus expects it in range
+// selectors and record names, e.g. 720h becomes 30d. A zero duration is left
+// empty so that it is defaulted by the v1alpha1 webhook.
+func formatDuration(duration metav1.Duration) string {
+	if duration.Duration == 0 {
+		return ""
+	}
+	return model.Duration(duration.Duration).String()
+}
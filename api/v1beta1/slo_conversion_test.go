@@ -0,0 +1,82 @@
+package v1beta1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+)
+
+func TestConvertRoundTripsThroughHub(t *testing.T) {
+	shortWindow := false
+	hub := &v1alpha1.Slo{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-service",
+			Namespace: "test-ns",
+		},
+		Spec: v1alpha1.SloSpec{
+			Objectives: v1alpha1.Objectives{
+				Availability: "99.9",
+				Latency: []v1alpha1.LatencyTarget{{
+					LE:     "0.1",
+					Target: "95",
+				}},
+				Window: "30d",
+			},
+			ErrorRateRecord: v1alpha1.ExprBlock{
+				AlertMethod: "multi-window",
+				BurnRate:    "2",
+				ShortWindow: &shortWindow,
+				Windows: []v1alpha1.Window{{
+					Duration:     "1h",
+					Consumption:  "2",
+					Notification: "page",
+				}},
+				Expr: "sum(rate(http_requests_total{status=\"5xx\"}[$window]))",
+			},
+			Labels: map[string]string{
+				"team": "test-team",
+			},
+		},
+		Status: v1alpha1.SloStatus{
+			ObservedGeneration: 3,
+			Objective:          "99.9",
+			Ready:              corev1.ConditionTrue,
+			RuleName:           "test-service",
+			PrometheusRule: &v1alpha1.RuleReference{
+				Name:      "test-service",
+				Namespace: "test-ns",
+			},
+			InvalidRules: []v1alpha1.InvalidRule{{
+				Group: "slo:test-service:short",
+				Rule:  "slo:test_service:service_errors_total:ratio_rate_5m",
+				Expr:  "sum(rate(http_requests_total[5m])",
+				Error: "unclosed left parenthesis",
+			}},
+		},
+	}
+
+	spoke := &Slo{}
+	assert.NoError(t, spoke.ConvertFrom(hub))
+	assert.Equal(t, 30*24*time.Hour, spoke.Spec.Objectives.Window.Duration)
+	assert.Equal(t, time.Hour, spoke.Spec.ErrorRateRecord.Windows[0].Duration.Duration)
+	assert.Equal(t, Percent("99.9"), spoke.Spec.Objectives.Availability)
+
+	roundTripped := &v1alpha1.Slo{}
+	assert.NoError(t, spoke.ConvertTo(roundTripped))
+	assert.Equal(t, hub, roundTripped)
+}
+
+func TestConvertFromRejectsInvalidDuration(t *testing.T) {
+	hub := &v1alpha1.Slo{
+		Spec: v1alpha1.SloSpec{
+			Objectives: v1alpha1.Objectives{Window: "a month"},
+		},
+	}
+
+	assert.Error(t, (&Slo{}).ConvertFrom(hub))
+}
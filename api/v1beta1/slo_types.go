@@ -0,0 +1,282 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Percent is a number between 0 and 100 written as a string, for example "99.9"
+// +kubebuilder:validation:Pattern=`^(100(\.0+)?|[0-9]{1,2}(\.[0-9]+)?)$`
+type Percent string
+
+// Decimal is a non-negative number written as a string, for example "14.4"
+// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+type Decimal string
+
+// SloSpec defines the desired state of Slo
+type SloSpec struct {
+	Objectives Objectives `json:"objectives"`
+
+	// +kubebuilder:validation:Optional
+	TrafficRateRecord ExprBlock `json:"trafficRateRecord,omitempty"`
+	// +kubebuilder:validation:Optional
+	ErrorRateRecord ExprBlock `json:"errorRateRecord,omitempty"`
+	// +kubebuilder:validation:Optional
+	LatencyRecord ExprBlock `json:"latencyRecord,omitempty"`
+	// +kubebuilder:validation:Optional
+	LatencyQuantileRecord ExprBlock `json:"latencyQuantileRecord,omitempty"`
+	// +kubebuilder:validation:Optional
+	Labels map[string]string `json:"labels,omitempty"`
+	// +kubebuilder:validation:Optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Samples overrides the operator default recording groups
+	// +kubebuilder:validation:Optional
+	Samples []Sample `json:"samples,omitempty"`
+	// RuleNamespace is the namespace the PrometheusRule is written to, it
+	// overrides the operator default and falls back to the namespace of the Slo
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=63
+	RuleNamespace string `json:"ruleNamespace,omitempty"`
+	// RuleLabels are added to the labels of the PrometheusRule, over the
+	// operator default rule labels
+	// +kubebuilder:validation:Optional
+	RuleLabels map[string]string `json:"ruleLabels,omitempty"`
+	// PrometheusRef names the Prometheus that should load the rules. The
+	// PrometheusRule gets the labels its ruleSelector requires.
+	// +kubebuilder:validation:Optional
+	PrometheusRef *PrometheusReference `json:"prometheusRef,omitempty"`
+}
+
+// Sample is a group of recording rules evaluated at Interval, recording every
+// expression over each of the Buckets windows
+type Sample struct {
+	Name     string          `json:"name"`
+	Interval metav1.Duration `json:"interval"`
+	// +kubebuilder:validation:MinItems=1
+	Buckets []metav1.Duration `json:"buckets"`
+	// RecordQuantiles disables the latencyQuantileRecord rules of the group when false
+	// +kubebuilder:validation:Optional
+	RecordQuantiles *bool `json:"recordQuantiles,omitempty"`
+}
+
+// SloStatus defines the observed state of Slo
+type SloStatus struct {
+	// ObservedGeneration is the most recent generation of the Slo seen by the controller
+	// +kubebuilder:validation:Optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +kubebuilder:validation:Optional
+	Conditions []SloCondition `json:"conditions,omitempty"`
+	// PrometheusRule references the PrometheusRule generated for the Slo
+	// +kubebuilder:validation:Optional
+	PrometheusRule *RuleReference `json:"prometheusRule,omitempty"`
+	// RecordingRules lists the names of the recording rules emitted into the PrometheusRule
+	// +kubebuilder:validation:Optional
+	RecordingRules []string `json:"recordingRules,omitempty"`
+	// InvalidRules lists the generated rules that are not valid PromQL, the
+	// PrometheusRule is not written while there are any
+	// +kubebuilder:validation:Optional
+	InvalidRules []InvalidRule `json:"invalidRules,omitempty"`
+
+	// The fields below flatten the spec and the conditions for the printer columns
+
+	// Objective is the availability objective in percent
+	// +kubebuilder:validation:Optional
+	Objective string `json:"objective,omitempty"`
+	// Window is the objectives window
+	// +kubebuilder:validation:Optional
+	Window string `json:"window,omitempty"`
+	// AlertMethods lists the distinct alert methods of the records, comma separated
+	// +kubebuilder:validation:Optional
+	AlertMethods string `json:"alertMethods,omitempty"`
+	// Ready is the status of the Ready condition
+	// +kubebuilder:validation:Optional
+	Ready corev1.ConditionStatus `json:"ready,omitempty"`
+	// RuleName is the name of the generated PrometheusRule
+	// +kubebuilder:validation:Optional
+	RuleName string `json:"ruleName,omitempty"`
+	// BudgetRemaining is the percentage of the error budget of the availability
+	// objective that is left, when it is known
+	// +kubebuilder:validation:Optional
+	BudgetRemaining string `json:"budgetRemaining,omitempty"`
+	// Live holds the current values of the records read from Prometheus, when
+	// the operator is started with a Prometheus URL
+	// +kubebuilder:validation:Optional
+	Live *LiveStatus `json:"live,omitempty"`
+}
+
+// LiveStatus is the current state of the Slo as recorded by Prometheus. The values
+// are empty until Prometheus has evaluated the records.
+type LiveStatus struct {
+	// SLI is the availability over the objectives window, in percent
+	// +kubebuilder:validation:Optional
+	SLI string `json:"sli,omitempty"`
+	// BudgetRemaining is the part of the error budget left over the objectives window, in percent
+	// +kubebuilder:validation:Optional
+	BudgetRemaining string `json:"budgetRemaining,omitempty"`
+	// PageBurnRate is the burn rate over the first page window
+	// +kubebuilder:validation:Optional
+	PageBurnRate string `json:"pageBurnRate,omitempty"`
+	// TicketBurnRate is the burn rate over the first ticket window
+	// +kubebuilder:validation:Optional
+	TicketBurnRate string `json:"ticketBurnRate,omitempty"`
+	// LastQueryTime is when Prometheus was last queried
+	// +kubebuilder:validation:Optional
+	LastQueryTime metav1.Time `json:"lastQueryTime,omitempty"`
+	// LastUpdateTime is when the values were last read successfully
+	// +kubebuilder:validation:Optional
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+	// Error is the error of the last query, if it failed
+	// +kubebuilder:validation:Optional
+	Error string `json:"error,omitempty"`
+}
+
+type SloCondition struct {
+	Type   string                 `json:"type"`
+	Status corev1.ConditionStatus `json:"status"`
+	// +kubebuilder:validation:Optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +kubebuilder:validation:Optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+	// +kubebuilder:validation:Optional
+	Reason string `json:"reason,omitempty"`
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitempty"`
+}
+
+type RuleReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// PrometheusReference references a Prometheus of the Prometheus operator
+type PrometheusReference struct {
+	Name string `json:"name"`
+	// Namespace of the Prometheus, the namespace of the Slo when empty
+	// +kubebuilder:validation:Optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// InvalidRule is a generated rule whose expression failed to parse
+type InvalidRule struct {
+	Group string `json:"group"`
+	// Rule is the record or alert name of the rule
+	Rule  string `json:"rule"`
+	Expr  string `json:"expr"`
+	Error string `json:"error"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=slo
+// +kubebuilder:printcolumn:name="Objective",type=string,JSONPath=`.status.objective`
+// +kubebuilder:printcolumn:name="Window",type=string,JSONPath=`.status.window`
+// +kubebuilder:printcolumn:name="Alert Methods",type=string,JSONPath=`.status.alertMethods`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.ready`
+// +kubebuilder:printcolumn:name="Rule",type=string,JSONPath=`.status.ruleName`
+// +kubebuilder:printcolumn:name="Budget Remaining",type=string,JSONPath=`.status.budgetRemaining`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Slo is the Schema for the sloes API
+type Slo struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SloSpec   `json:"spec,omitempty"`
+	Status SloStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SloList contains a list of Slo
+type SloList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Slo `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Slo{}, &SloList{})
+}
+
+type ExprBlock struct {
+	// +kubebuilder:validation:Optional
+	AlertMethod string `json:"alertMethod,omitempty"`
+	// +kubebuilder:validation:Optional
+	BurnRate Decimal `json:"burnRate,omitempty"`
+	// +kubebuilder:validation:Optional
+	Windows []Window `json:"windows,omitempty"`
+	// +kubebuilder:validation:Optional
+	ShortWindow *bool `json:"shortWindow,omitempty"`
+	// +kubebuilder:validation:Optional
+	Buckets []string `json:"buckets,omitempty"` // used to define buckets of histogram when using latency expression
+	// Quantiles recorded by the latencyQuantileRecord, such as "0.9" or "0.999"
+	// +kubebuilder:validation:Optional
+	Quantiles []Decimal `json:"quantiles,omitempty"`
+	// BudgetThresholds of the error-budget alert method
+	// +kubebuilder:validation:Optional
+	BudgetThresholds []BudgetThreshold `json:"budgetThresholds,omitempty"`
+	// Forecast of the forecast alert method
+	// +kubebuilder:validation:Optional
+	Forecast *Forecast `json:"forecast,omitempty"`
+	// MinRate of the trafficRateRecord, alerts are only raised for windows whose traffic rate is above it
+	// +kubebuilder:validation:Optional
+	MinRate Decimal `json:"minRate,omitempty"`
+	// +kubebuilder:validation:Optional
+	Expr string `json:"expr,omitempty"`
+}
+
+type Window struct {
+	Duration    metav1.Duration `json:"duration"`
+	Consumption Percent         `json:"consumption"`
+	// +kubebuilder:validation:Enum=page;ticket
+	Notification string `json:"notification"`
+}
+
+// BudgetThreshold alerts once no more than Remaining percent of the error
+// budget over the objectives window is left
+type BudgetThreshold struct {
+	Remaining Percent `json:"remaining"`
+	// +kubebuilder:validation:Enum=page;ticket
+	Notification string `json:"notification"`
+}
+
+// Forecast alerts when the error budget runs out within Horizon at the burn
+// rate over Lookback
+type Forecast struct {
+	// +kubebuilder:validation:Optional
+	Horizon metav1.Duration `json:"horizon,omitempty"`
+	// +kubebuilder:validation:Optional
+	Lookback metav1.Duration `json:"lookback,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=page;ticket
+	Notification string `json:"notification,omitempty"`
+}
+
+type Objectives struct {
+	Availability Percent         `json:"availability"`
+	Latency      []LatencyTarget `json:"latency,omitempty"`
+	// +kubebuilder:validation:Optional
+	Window metav1.Duration `json:"window,omitempty"`
+}
+
+type LatencyTarget struct {
+	LE     string  `json:"le"`
+	Target Percent `json:"target"`
+}
@@ -0,0 +1,101 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+)
+
+// log is for logging in this package.
+var slolog = logf.Log.WithName("slo-resource")
+
+// SetupWebhookWithManager registers the admission webhooks of this version and
+// the conversion webhook shared by all versions of the Slo.
+func (r *Slo) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// Defaulting and validation are delegated to the hub so that both versions
+// accept exactly the same objects.
+
+// +kubebuilder:webhook:path=/mutate-monitoring-kanzifucius-com-v1beta1-slo,mutating=true,failurePolicy=fail,groups=monitoring.kanzifucius.com,resources=sloes,verbs=create;update,versions=v1beta1,name=mslo.v1beta1.kb.io
+
+var _ webhook.Defaulter = &Slo{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *Slo) Default() {
+	slolog.Info("default", "name", r.Name)
+
+	hub := &v1alpha1.Slo{}
+	if err := r.ConvertTo(hub); err != nil {
+		slolog.Error(err, "failed to convert to hub, skipping defaults", "name", r.Name)
+		return
+	}
+	hub.Default()
+	if err := r.ConvertFrom(hub); err != nil {
+		slolog.Error(err, "failed to convert from hub, skipping defaults", "name", r.Name)
+	}
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-monitoring-kanzifucius-com-v1beta1-slo,mutating=false,failurePolicy=fail,groups=monitoring.kanzifucius.com,resources=sloes,versions=v1beta1,name=vslo.v1beta1.kb.io
+
+var _ webhook.Validator = &Slo{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Slo) ValidateCreate() error {
+	slolog.Info("validate create", "name", r.Name)
+
+	return r.validateSlo()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Slo) ValidateUpdate(old runtime.Object) error {
+	slolog.Info("validate update", "name", r.Name)
+
+	oldSlo, ok := old.(*Slo)
+	if !ok {
+		return r.validateSlo()
+	}
+	hub, oldHub := &v1alpha1.Slo{}, &v1alpha1.Slo{}
+	if err := r.ConvertTo(hub); err != nil {
+		return err
+	}
+	if err := oldSlo.ConvertTo(oldHub); err != nil {
+		return err
+	}
+	return hub.ValidateUpdate(oldHub)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Slo) ValidateDelete() error {
+	return nil
+}
+
+func (r *Slo) validateSlo() error {
+	hub := &v1alpha1.Slo{}
+	if err := r.ConvertTo(hub); err != nil {
+		return err
+	}
+	return hub.ValidateCreate()
+}
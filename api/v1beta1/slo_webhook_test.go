@@ -0,0 +1,34 @@
+package v1beta1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+)
+
+func TestValidateUpdateSkipsUnchangedSpec(t *testing.T) {
+	// stored before the webhook existed, the expression is invalid today
+	hub := &v1alpha1.Slo{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-service", Namespace: "test-ns"},
+		Spec: v1alpha1.SloSpec{
+			Objectives:      v1alpha1.Objectives{Availability: "99.9", Window: "30d"},
+			ErrorRateRecord: v1alpha1.ExprBlock{Expr: "sum(rate(http_requests_total[5m]))"},
+		},
+	}
+	hub.Default()
+	old := &Slo{}
+	if !assert.NoError(t, old.ConvertFrom(hub)) {
+		return
+	}
+	assert.Error(t, old.ValidateCreate())
+
+	updated := old.DeepCopy()
+	updated.Finalizers = []string{"slo.monitoring.kanzifucius.com"}
+	assert.NoError(t, updated.ValidateUpdate(old), "a metadata-only update is not validated")
+
+	updated.Spec.Objectives.Availability = "99.5"
+	assert.Error(t, updated.ValidateUpdate(old))
+}
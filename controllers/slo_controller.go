@@ -18,32 +18,64 @@ package controllers
 
 import (
 	"context"
+	goerrors "errors"
+	"fmt"
 	"github.com/kanzifucius/promethues-operator-slos/pkg/slo"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
-	"reflect"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sort"
+	"time"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	monitoringv1alpha1 "github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
 	promoperator "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 )
 
-const sloFinalizer = "slo.monitoring.kanzifucius.com"
+const (
+	sloFinalizer = "slo.monitoring.kanzifucius.com"
+	// fieldManager owns the fields of the PrometheusRules applied by the operator
+	fieldManager = "slo-operator"
+	// legacyFieldManager owns the fields of the PrometheusRules written with
+	// Update by operator versions before server-side apply, it is derived from
+	// the name of the manager binary
+	legacyFieldManager = "manager"
+)
 
 // SloReconciler reconciles a Slo object
 type SloReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+	// Options are the operator wide settings used to generate rules
+	Options slo.Options
+	// Recorder emits events on the Slos
+	Recorder record.EventRecorder
+	// Prometheus is queried for the live status of the Slos, which is not
+	// maintained when it is nil
+	Prometheus slo.Querier
+	// ResyncPeriod is the interval at which the live status is refreshed
+	ResyncPeriod time.Duration
 }
 
 // +kubebuilder:rbac:groups=monitoring.kanzifucius.com,resources=sloes,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=monitoring.kanzifucius.com,resources=sloes/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheusrules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *SloReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	log := r.Log.WithValues("slo", req.NamespacedName)
@@ -71,7 +103,7 @@ func (r *SloReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	if isSloToBeDeleted {
 		if contains(sloDefinition.GetFinalizers(), sloFinalizer) {
 
-			if err := r.addFinalizer(log, sloDefinition); err != nil {
+			if err := r.finalizeSLO(log, sloDefinition); err != nil {
 				return ctrl.Result{}, err
 			}
 			// Remove Finalizer. Once all finalizers have been
@@ -85,66 +117,386 @@ func (r *SloReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return ctrl.Result{}, nil
 	}
 
-	found := &promoperator.PrometheusRule{}
-	err = r.Get(ctx, types.NamespacedName{Name: sloDefinition.Name, Namespace: sloDefinition.Namespace}, found)
-	if err != nil && errors.IsNotFound(err) {
-		// Define a new deployment
-		rule, err := slo.GeneratePromRules(sloDefinition)
-		if err != nil {
-			log.Error(err, "Failed to generate Prometheus rule ")
+	if !contains(sloDefinition.GetFinalizers(), sloFinalizer) {
+		if err := r.addFinalizer(log, sloDefinition); err != nil {
+			if errors.IsInvalid(err) {
+				// the webhook rejects Slos stored before it validated their
+				// spec, report why instead of only retrying
+				r.reportRejectedFinalizer(ctx, log, sloDefinition, err)
+			}
 			return ctrl.Result{}, err
 		}
-		err = ctrl.SetControllerReference(sloDefinition, rule, r.Scheme)
-		if err != nil {
-			log.Error(err, "Failed to set owner for Prometheus rule")
-			return ctrl.Result{}, err
+	}
+
+	rule, err := r.generate(ctx, sloDefinition)
+	if err != nil {
+		log.Error(err, "Failed to generate Prometheus rule ")
+		// the rule applied for an earlier generation is left in place
+		ruleExists, getErr := r.appliedRuleExists(ctx, sloDefinition)
+		if getErr != nil {
+			log.Error(getErr, "Failed to get Prometheus rule")
+			return ctrl.Result{}, getErr
+		}
+		setGenerationFailed(sloDefinition, err, ruleExists)
+		var invalid *slo.InvalidRulesError
+		goerrors.As(err, &invalid)
+		setInvalidRules(sloDefinition, invalid)
+		if invalid != nil {
+			for _, rule := range invalid.Rules {
+				r.Recorder.Eventf(sloDefinition, corev1.EventTypeWarning, reasonInvalidRule, "%s in group %s: %v", rule.Rule, rule.Group, rule.Err)
+			}
+		} else {
+			r.Recorder.Event(sloDefinition, corev1.EventTypeWarning, generationFailedReason(err), err.Error())
 		}
-		log.Info("Creating a new Prometheus Rules 	", "rule", rule.Name)
-		err = r.Create(ctx, rule)
-		if err != nil {
-			r.Log.Error(err, "Failed to create new Prometheus")
+		return ctrl.Result{}, r.updateStatus(ctx, log, sloDefinition)
+	}
+
+	found := &promoperator.PrometheusRule{}
+	err = r.Get(ctx, types.NamespacedName{Name: rule.Name, Namespace: rule.Namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "Failed to get Prometheus rule")
+		return ctrl.Result{}, err
+	}
+	ruleExists := err == nil
+
+	conflicts, err := r.recordNameConflicts(ctx, sloDefinition)
+	if err != nil {
+		log.Error(err, "Failed to list Slos")
+		return ctrl.Result{}, err
+	}
+	setNameConflict(sloDefinition, conflicts)
+
+	if ruleExists && !managedBy(found, sloDefinition) {
+		err = fmt.Errorf("PrometheusRule %s/%s exists and is not managed by this Slo", found.Namespace, found.Name)
+		log.Error(err, "Refusing to overwrite Prometheus rule")
+		setApplyFailed(sloDefinition, err)
+		r.Recorder.Event(sloDefinition, corev1.EventTypeWarning, reasonApplyFailed, err.Error())
+		return ctrl.Result{}, r.updateStatus(ctx, log, sloDefinition)
+	}
 
+	if ruleExists {
+		if err := r.migrateManagedFields(ctx, found); err != nil {
+			log.Error(err, "Failed to migrate the managed fields of Prometheus rule", "rule", found.Namespace+"/"+found.Name)
 			return ctrl.Result{}, err
 		}
-		// Deployment created successfully - return and requeue
-		return ctrl.Result{Requeue: true}, nil
-	} else if err != nil {
-		log.Error(err, "Failed to get Slo")
+	}
+
+	applied, err := r.applyRule(ctx, sloDefinition, rule)
+	if err != nil {
+		log.Error(err, "Failed to apply Prometheus rule", "rule", rule.Namespace+"/"+rule.Name)
+		setApplyFailed(sloDefinition, err)
+		r.Recorder.Event(sloDefinition, corev1.EventTypeWarning, reasonApplyFailed, err.Error())
+		if statusErr := r.updateStatus(ctx, log, sloDefinition); statusErr != nil {
+			log.Error(statusErr, "Failed to update Slo status")
+		}
 		return ctrl.Result{}, err
 	}
+	if !ruleExists {
+		log.Info("Created Prometheus rule", "rule", applied.Namespace+"/"+applied.Name)
+		r.Recorder.Eventf(sloDefinition, corev1.EventTypeNormal, reasonRuleCreated, "Created PrometheusRule %s/%s with %d groups", applied.Namespace, applied.Name, len(applied.Spec.Groups))
+	} else if applied.ResourceVersion != found.ResourceVersion {
+		// the apply is a no-op unless the spec, labels, annotations or owner
+		// reference of the rule drifted from the generated ones
+		changes := slo.CompareGroups(found.Spec.Groups, applied.Spec.Groups)
+		summary := changes.String()
+		if changes.Empty() {
+			summary = "metadata changed"
+		}
+		log.Info("Updated Prometheus rule", "rule", applied.Namespace+"/"+applied.Name, "changes", summary)
+		r.Recorder.Eventf(sloDefinition, corev1.EventTypeNormal, reasonRuleUpdated, "Updated PrometheusRule %s/%s: %s", applied.Namespace, applied.Name, summary)
+	}
 
-	// check if we need to update the rule
-	rule, err := slo.GeneratePromRules(sloDefinition)
-	if !reflect.DeepEqual(found.Spec, rule.Spec) {
-		found.Spec = rule.Spec
-		err = ctrl.SetControllerReference(sloDefinition, rule, r.Scheme)
-		if err != nil {
-			log.Error(err, "Failed to update owner for Prometheus rule")
-			return ctrl.Result{}, err
+	if err := r.deleteStaleRules(ctx, log, sloDefinition, rule); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	setReady(sloDefinition, rule)
+	if r.Prometheus == nil {
+		return ctrl.Result{}, r.updateStatus(ctx, log, sloDefinition)
+	}
+
+	// status updates trigger reconciles as well, Prometheus is only queried
+	// once per resync period
+	if wait := r.untilLiveQuery(sloDefinition, time.Now()); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, r.updateStatus(ctx, log, sloDefinition)
+	}
+	r.updateLiveStatus(ctx, log, sloDefinition)
+	return ctrl.Result{RequeueAfter: r.ResyncPeriod}, r.updateStatus(ctx, log, sloDefinition)
+}
+
+// untilLiveQuery returns how long to wait before the live status is due
+func (r *SloReconciler) untilLiveQuery(sloDefinition *monitoringv1alpha1.Slo, now time.Time) time.Duration {
+	live := sloDefinition.Status.Live
+	if live == nil || live.LastQueryTime.IsZero() {
+		return 0
+	}
+	return live.LastQueryTime.Add(r.ResyncPeriod).Sub(now)
+}
+
+// updateLiveStatus reads the current values of the records from Prometheus.
+// Query errors are reported in the live status and the last values are kept.
+func (r *SloReconciler) updateLiveStatus(ctx context.Context, log logr.Logger, sloDefinition *monitoringv1alpha1.Slo) {
+	now := metav1.Now()
+	values, err := slo.QueryLiveValues(ctx, r.Prometheus, sloDefinition, r.Options, now.Time)
+
+	live := sloDefinition.Status.Live
+	if live == nil {
+		live = &monitoringv1alpha1.LiveStatus{}
+	}
+	live.LastQueryTime = now
+	if err != nil {
+		log.Error(err, "Failed to query Prometheus")
+		live.Error = err.Error()
+	} else {
+		setLiveValues(live, values)
+		live.LastUpdateTime = now
+		live.Error = ""
+	}
+	sloDefinition.Status.Live = live
+}
+
+// recordNameConflicts returns the namespaced names of the other Slos whose
+// records are named the same as those of sloDefinition
+func (r *SloReconciler) recordNameConflicts(ctx context.Context, sloDefinition *monitoringv1alpha1.Slo) ([]string, error) {
+	others, err := r.slosWithSameRecordName(ctx, sloDefinition)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []string
+	for _, other := range others {
+		conflicts = append(conflicts, other.Namespace+"/"+other.Name)
+	}
+	sort.Strings(conflicts)
+	return conflicts, nil
+}
+
+// slosWithSameRecordName returns the other Slos whose records have the same
+// names as those of the Slo. Slos being deleted no longer count, their rules
+// are removed by the finalizer.
+func (r *SloReconciler) slosWithSameRecordName(ctx context.Context, sloDefinition *monitoringv1alpha1.Slo) ([]monitoringv1alpha1.Slo, error) {
+	sloList := &monitoringv1alpha1.SloList{}
+	if err := r.List(ctx, sloList); err != nil {
+		return nil, err
+	}
+
+	name := r.Options.RecordName(sloDefinition)
+	var others []monitoringv1alpha1.Slo
+	for _, other := range sloList.Items {
+		if other.UID == sloDefinition.UID || other.DeletionTimestamp != nil {
+			continue
 		}
-		log.Info("Creating a new Prometheus Rules 	", "rule", rule.Name)
-		err = r.Update(ctx, found)
-		if err != nil {
-			r.Log.Error(err, "Failed to update new Prometheus")
+		if r.Options.RecordName(&other) == name {
+			others = append(others, other)
+		}
+	}
+	return others, nil
+}
 
-			return ctrl.Result{}, err
+// conflictingSlos maps a Slo that was created or deleted to the other Slos
+// whose records have the same names, so that the NameConflict condition is
+// kept on both sides of the conflict
+func (r *SloReconciler) conflictingSlos(object handler.MapObject) []reconcile.Request {
+	sloDefinition, ok := object.Object.(*monitoringv1alpha1.Slo)
+	if !ok {
+		return nil
+	}
+	others, err := r.slosWithSameRecordName(context.Background(), sloDefinition)
+	if err != nil {
+		r.Log.Error(err, "Failed to list Slos")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, other := range others {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: other.Name, Namespace: other.Namespace}})
+	}
+	return requests
+}
+
+// generate defaults and validates the spec, for Slos that were admitted
+// without the webhook, and generates the rules of the defaulted spec
+func (r *SloReconciler) generate(ctx context.Context, sloDefinition *monitoringv1alpha1.Slo) (*promoperator.PrometheusRule, error) {
+	defaulted := sloDefinition.DeepCopy()
+	defaulted.Default()
+	if allErrs := defaulted.Spec.Validate(field.NewPath("spec")); len(allErrs) > 0 {
+		return nil, errors.NewInvalid(monitoringv1alpha1.GroupVersion.WithKind("Slo").GroupKind(), sloDefinition.Name, allErrs)
+	}
+
+	options, selectorLabels, err := r.prometheusOptions(ctx, defaulted)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := slo.GeneratePromRules(defaulted, options)
+	if err != nil {
+		return nil, err
+	}
+	if !r.Options.RuleNamespaceAllowed(defaulted.Namespace, rule.Namespace) {
+		path := field.NewPath("spec", "ruleNamespace")
+		if defaulted.Spec.RuleNamespace == "" {
+			path = field.NewPath("spec", "prometheusRef")
 		}
-		// Deployment created successfully - return and requeue
-		return ctrl.Result{Requeue: true}, nil
+		return nil, &slo.FieldError{Field: path, Err: fmt.Errorf("the operator does not allow rules in namespace %s", rule.Namespace)}
+	}
+	for key, value := range selectorLabels {
+		rule.Labels[key] = value
 	}
+	return rule, nil
+}
 
-	return ctrl.Result{}, nil
+// appliedRuleExists reports whether the PrometheusRule recorded in the status
+// of the Slo still exists
+func (r *SloReconciler) appliedRuleExists(ctx context.Context, sloDefinition *monitoringv1alpha1.Slo) (bool, error) {
+	ref := sloDefinition.Status.PrometheusRule
+	if ref == nil {
+		return false, nil
+	}
+	err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: ref.Namespace}, &promoperator.PrometheusRule{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
 }
 
+// finalizeSLO deletes the PrometheusRules generated for the Slo. Owner
+// references only garbage collect rules in the namespace of the Slo, the rules
+// are found by their tracking labels, and by name for rules generated before
+// the labels were set.
 func (r *SloReconciler) finalizeSLO(reqLogger logr.Logger, monitoringv1alpha1Slo *monitoringv1alpha1.Slo) error {
-	// TODO(user): Add the cleanup steps that the operator
-	// needs to do before the CR can be deleted. Examples
-	// of finalizers include performing backups and deleting
-	// resources that are not owned by this CR, like a PVC.
+	ctx := context.TODO()
+
+	ruleList := &promoperator.PrometheusRuleList{}
+	if err := r.List(ctx, ruleList, client.MatchingLabels(slo.TrackingLabels(monitoringv1alpha1Slo))); err != nil {
+		reqLogger.Error(err, "Failed to list Prometheus rules")
+		return err
+	}
+	rules := ruleList.Items
+
+	rule := &promoperator.PrometheusRule{}
+	err := r.Get(ctx, types.NamespacedName{Name: monitoringv1alpha1Slo.Name, Namespace: monitoringv1alpha1Slo.Namespace}, rule)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && metav1.IsControlledBy(rule, monitoringv1alpha1Slo) && !containsRule(rules, rule) {
+		rules = append(rules, rule)
+	}
+
+	for _, rule := range rules {
+		if err := r.Delete(ctx, rule); err != nil && !errors.IsNotFound(err) {
+			reqLogger.Error(err, "Failed to delete Prometheus rule", "rule", rule.Namespace+"/"+rule.Name)
+			return err
+		}
+		r.Recorder.Eventf(monitoringv1alpha1Slo, corev1.EventTypeNormal, reasonRuleDeleted, "Deleted PrometheusRule %s/%s", rule.Namespace, rule.Name)
+	}
+
+	r.Recorder.Eventf(monitoringv1alpha1Slo, corev1.EventTypeNormal, reasonCleanupFinished, "Deleted %d generated PrometheusRules", len(rules))
 	reqLogger.Info("Successfully finalized slo")
 	return nil
 }
 
+// applyRule writes the generated rule with server-side apply. The operator owns
+// the spec and its labels, annotations and owner reference of the rule, so
+// manual edits of those are reverted and changes of the Slo metadata reach the
+// rule, while fields set by other managers are kept.
+func (r *SloReconciler) applyRule(ctx context.Context, sloDefinition *monitoringv1alpha1.Slo, rule *promoperator.PrometheusRule) (*promoperator.PrometheusRule, error) {
+	applied := rule.DeepCopy()
+	// owner references cannot cross namespaces, rules written to another
+	// namespace are tracked by their labels and deleted by the finalizer
+	if applied.Namespace == sloDefinition.Namespace {
+		if err := ctrl.SetControllerReference(sloDefinition, applied, r.Scheme); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.Patch(ctx, applied, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// migrateManagedFields hands the fields of a rule written with Update by an
+// operator version before server-side apply over to the fieldManager. Without
+// it the legacy manager keeps owning them, and labels, annotations or groups
+// the operator no longer generates would never be removed by the apply. It only
+// runs before the first apply of the rule.
+func (r *SloReconciler) migrateManagedFields(ctx context.Context, rule *promoperator.PrometheusRule) error {
+	var legacy []int
+	for i, entry := range rule.GetManagedFields() {
+		if entry.Manager == fieldManager && entry.Operation == metav1.ManagedFieldsOperationApply {
+			return nil
+		}
+		if entry.Manager == legacyFieldManager && entry.Operation == metav1.ManagedFieldsOperationUpdate {
+			legacy = append(legacy, i)
+		}
+	}
+	if len(legacy) == 0 {
+		return nil
+	}
+
+	patch := client.MergeFrom(rule.DeepCopy())
+	for _, i := range legacy {
+		rule.ManagedFields[i].Manager = fieldManager
+		rule.ManagedFields[i].Operation = metav1.ManagedFieldsOperationApply
+	}
+	return r.Patch(ctx, rule, patch)
+}
+
+// deleteStaleRules deletes the rules generated for the Slo other than current,
+// left behind when the rule namespace of the Slo changed
+func (r *SloReconciler) deleteStaleRules(ctx context.Context, log logr.Logger, sloDefinition *monitoringv1alpha1.Slo, current *promoperator.PrometheusRule) error {
+	ruleList := &promoperator.PrometheusRuleList{}
+	if err := r.List(ctx, ruleList, client.MatchingLabels(slo.TrackingLabels(sloDefinition))); err != nil {
+		log.Error(err, "Failed to list Prometheus rules")
+		return err
+	}
+
+	for _, rule := range ruleList.Items {
+		if rule.Namespace == current.Namespace && rule.Name == current.Name {
+			continue
+		}
+		if err := r.Delete(ctx, rule); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete stale Prometheus rule", "rule", rule.Namespace+"/"+rule.Name)
+			return err
+		}
+		r.Recorder.Eventf(sloDefinition, corev1.EventTypeNormal, reasonRuleDeleted, "Deleted stale PrometheusRule %s/%s", rule.Namespace, rule.Name)
+	}
+	return nil
+}
+
+// managedBy reports whether the rule was generated for the Slo
+func managedBy(rule *promoperator.PrometheusRule, sloDefinition *monitoringv1alpha1.Slo) bool {
+	if metav1.IsControlledBy(rule, sloDefinition) {
+		return true
+	}
+	for key, value := range slo.TrackingLabels(sloDefinition) {
+		if rule.Labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// rulesToSlo maps a PrometheusRule to the Slo it was generated for, through
+// its tracking labels, which also covers rules in other namespaces
+func rulesToSlo(object handler.MapObject) []reconcile.Request {
+	labels := object.Meta.GetLabels()
+	name, namespace := labels[slo.LabelSloName], labels[slo.LabelSloNamespace]
+	if name == "" || namespace == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}}
+}
+
+func containsRule(rules []*promoperator.PrometheusRule, rule *promoperator.PrometheusRule) bool {
+	for _, r := range rules {
+		if r.Namespace == rule.Namespace && r.Name == rule.Name {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *SloReconciler) addFinalizer(reqLogger logr.Logger, monitoringv1alpha1Slo *monitoringv1alpha1.Slo) error {
 	reqLogger.Info("Adding Finalizer for the slo")
 	controllerutil.AddFinalizer(monitoringv1alpha1Slo, sloFinalizer)
@@ -158,6 +510,22 @@ func (r *SloReconciler) addFinalizer(reqLogger logr.Logger, monitoringv1alpha1Sl
 	return nil
 }
 
+// reportRejectedFinalizer records in the status and in an event that the
+// finalizer could not be added because the spec of the Slo is invalid
+func (r *SloReconciler) reportRejectedFinalizer(ctx context.Context, log logr.Logger, sloDefinition *monitoringv1alpha1.Slo, err error) {
+	controllerutil.RemoveFinalizer(sloDefinition, sloFinalizer)
+	ruleExists, getErr := r.appliedRuleExists(ctx, sloDefinition)
+	if getErr != nil {
+		log.Error(getErr, "Failed to get Prometheus rule")
+		return
+	}
+	setGenerationFailed(sloDefinition, err, ruleExists)
+	r.Recorder.Event(sloDefinition, corev1.EventTypeWarning, generationFailedReason(err), err.Error())
+	if statusErr := r.updateStatus(ctx, log, sloDefinition); statusErr != nil {
+		log.Error(statusErr, "Failed to update Slo status")
+	}
+}
+
 func contains(list []string, s string) bool {
 	for _, v := range list {
 		if v == s {
@@ -170,6 +538,19 @@ func contains(list []string, s string) bool {
 func (r *SloReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&monitoringv1alpha1.Slo{}).
+		// record names only change when a Slo is created or deleted
+		Watches(&source.Kind{Type: &monitoringv1alpha1.Slo{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.conflictingSlos),
+		}, builder.WithPredicates(predicate.Funcs{
+			UpdateFunc:  func(event.UpdateEvent) bool { return false },
+			GenericFunc: func(event.GenericEvent) bool { return false },
+		})).
 		Owns(&promoperator.PrometheusRule{}).
+		Watches(&source.Kind{Type: &promoperator.PrometheusRule{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(rulesToSlo),
+		}).
+		Watches(&source.Kind{Type: &promoperator.Prometheus{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.slosForPrometheus),
+		}).
 		Complete(r)
 }
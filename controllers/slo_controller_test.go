@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"testing"
+
+	monitoringv1alpha1 "github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+	"github.com/kanzifucius/promethues-operator-slos/pkg/slo"
+	promoperator "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Slos admitted without the webhook are generated as if they had been defaulted
+func TestGenerateDefaultsTheSpec(t *testing.T) {
+	sloDefinition := &monitoringv1alpha1.Slo{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-service", Namespace: "test-ns"},
+		Spec: monitoringv1alpha1.SloSpec{
+			Objectives: monitoringv1alpha1.Objectives{Availability: "99.9"},
+			ErrorRateRecord: monitoringv1alpha1.ExprBlock{
+				Expr: "sum(rate(http_requests_total{status=\"5xx\"}[$window])) / sum(rate(http_requests_total[$window]))",
+			},
+		},
+	}
+	defaulted := sloDefinition.DeepCopy()
+	defaulted.Default()
+
+	r := &SloReconciler{}
+	rule, err := r.generate(context.TODO(), sloDefinition)
+	if !assert.NoError(t, err) {
+		return
+	}
+	expected, err := r.generate(context.TODO(), defaulted)
+	assert.NoError(t, err)
+	assert.Equal(t, expected.Spec, rule.Spec)
+
+	var alerts []string
+	for _, group := range rule.Spec.Groups {
+		for _, r := range group.Rules {
+			if r.Alert != "" {
+				alerts = append(alerts, r.Alert)
+			}
+		}
+	}
+	assert.Equal(t, []string{"slo:test_service.errors.page", "slo:test_service.errors.ticket"}, alerts)
+	assert.Empty(t, sloDefinition.Spec.ErrorRateRecord.AlertMethod, "the Slo itself is not changed")
+}
+
+func TestGenerateRestrictsRuleNamespace(t *testing.T) {
+	sloDefinition := &monitoringv1alpha1.Slo{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-service", Namespace: "test-ns"},
+		Spec: monitoringv1alpha1.SloSpec{
+			Objectives:    monitoringv1alpha1.Objectives{Availability: "99.9"},
+			RuleNamespace: "kube-system",
+		},
+	}
+
+	r := &SloReconciler{Options: slo.Options{DefaultRuleNamespace: "monitoring"}}
+	_, err := r.generate(context.TODO(), sloDefinition)
+	var fieldErr *slo.FieldError
+	if assert.True(t, goerrors.As(err, &fieldErr), "%v", err) {
+		assert.Equal(t, "spec.ruleNamespace", fieldErr.Field.String())
+	}
+
+	r.Options.AllowedRuleNamespaces = []string{"kube-system"}
+	rule, err := r.generate(context.TODO(), sloDefinition)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "kube-system", rule.Namespace)
+	}
+
+	sloDefinition.Spec.RuleNamespace = "monitoring"
+	_, err = r.generate(context.TODO(), sloDefinition)
+	assert.NoError(t, err)
+}
+
+func TestMigrateManagedFields(t *testing.T) {
+	fields := &metav1.FieldsV1{Raw: []byte(`{"f:spec":{}}`)}
+	rule := func(managedFields ...metav1.ManagedFieldsEntry) *promoperator.PrometheusRule {
+		return &promoperator.PrometheusRule{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-service", Namespace: "test-ns", ManagedFields: managedFields},
+		}
+	}
+	legacy := metav1.ManagedFieldsEntry{Manager: legacyFieldManager, Operation: metav1.ManagedFieldsOperationUpdate, FieldsType: "FieldsV1", FieldsV1: fields}
+	applied := metav1.ManagedFieldsEntry{Manager: fieldManager, Operation: metav1.ManagedFieldsOperationApply, FieldsType: "FieldsV1", FieldsV1: fields}
+	other := metav1.ManagedFieldsEntry{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationUpdate, FieldsType: "FieldsV1", FieldsV1: fields}
+
+	tests := []struct {
+		name     string
+		rule     *promoperator.PrometheusRule
+		expected []metav1.ManagedFieldsEntry
+	}{
+		{"written with Update", rule(legacy, other), []metav1.ManagedFieldsEntry{applied, other}},
+		{"already applied", rule(applied, legacy), []metav1.ManagedFieldsEntry{applied, legacy}},
+		{"other managers only", rule(other), []metav1.ManagedFieldsEntry{other}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := prometheusReconciler(test.rule.DeepCopy())
+			found := &promoperator.PrometheusRule{}
+			if !assert.NoError(t, r.Get(context.TODO(), types.NamespacedName{Name: "test-service", Namespace: "test-ns"}, found)) {
+				return
+			}
+			assert.NoError(t, r.migrateManagedFields(context.TODO(), found))
+
+			stored := &promoperator.PrometheusRule{}
+			assert.NoError(t, r.Get(context.TODO(), types.NamespacedName{Name: "test-service", Namespace: "test-ns"}, stored))
+			assert.Equal(t, test.expected, stored.ManagedFields)
+		})
+	}
+}
+
+// rejectingClient fails updates of Slos the way the validating webhook does
+type rejectingClient struct {
+	client.Client
+}
+
+func (c rejectingClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if sloDefinition, ok := obj.(*monitoringv1alpha1.Slo); ok {
+		return apierrors.NewInvalid(monitoringv1alpha1.GroupVersion.WithKind("Slo").GroupKind(), sloDefinition.Name,
+			field.ErrorList{field.Invalid(field.NewPath("spec", "errorRateRecord", "expr"), "", "must contain $window")})
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+// Slos stored before the webhook validated their spec report why the finalizer
+// cannot be added
+func TestReconcileReportsRejectedFinalizer(t *testing.T) {
+	sloDefinition := &monitoringv1alpha1.Slo{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-service", Namespace: "test-ns", Generation: 1},
+		Spec: monitoringv1alpha1.SloSpec{
+			Objectives:      monitoringv1alpha1.Objectives{Availability: "99.9"},
+			ErrorRateRecord: monitoringv1alpha1.ExprBlock{Expr: "sum(rate(http_requests_total[5m]))"},
+		},
+	}
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = monitoringv1alpha1.AddToScheme(scheme)
+	_ = promoperator.AddToScheme(scheme)
+	recorder := record.NewFakeRecorder(10)
+	r := &SloReconciler{
+		Client:   rejectingClient{fake.NewFakeClientWithScheme(scheme, sloDefinition)},
+		Log:      logf.Log,
+		Scheme:   scheme,
+		Recorder: recorder,
+	}
+
+	_, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: "test-service", Namespace: "test-ns"}})
+	assert.True(t, apierrors.IsInvalid(err), "%v", err)
+
+	stored := &monitoringv1alpha1.Slo{}
+	if !assert.NoError(t, r.Get(context.TODO(), types.NamespacedName{Name: "test-service", Namespace: "test-ns"}, stored)) {
+		return
+	}
+	condition := stored.Status.GetCondition(monitoringv1alpha1.SloGenerationFailed)
+	if assert.NotNil(t, condition) {
+		assert.Equal(t, corev1.ConditionTrue, condition.Status)
+		assert.Equal(t, reasonValidationFailed, condition.Reason)
+	}
+	assert.Empty(t, stored.Finalizers)
+	if assert.Len(t, recorder.Events, 1) {
+		assert.Contains(t, <-recorder.Events, "Warning "+reasonValidationFailed)
+	}
+}
+
+func TestConflictingSlos(t *testing.T) {
+	newSlo := func(namespace, name string) *monitoringv1alpha1.Slo {
+		return &monitoringv1alpha1.Slo{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, UID: types.UID(namespace + "/" + name)}}
+	}
+	deleted := newSlo("team-d", "api")
+	now := metav1.Now()
+	deleted.DeletionTimestamp = &now
+	created := newSlo("team-a", "api")
+
+	scheme := runtime.NewScheme()
+	_ = monitoringv1alpha1.AddToScheme(scheme)
+	r := &SloReconciler{
+		Client: fake.NewFakeClientWithScheme(scheme, created, newSlo("team-b", "api"), newSlo("team-c", "web"), deleted),
+		Log:    logf.Log,
+	}
+
+	requests := r.conflictingSlos(handler.MapObject{Meta: created, Object: created})
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "api", Namespace: "team-b"}}}, requests)
+
+	conflicts, err := r.recordNameConflicts(context.TODO(), created)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"team-b/api"}, conflicts, "Slos being deleted no longer conflict")
+
+	r.Options.Naming = slo.NamingNamespaceName
+	assert.Empty(t, r.conflictingSlos(handler.MapObject{Meta: created, Object: created}))
+}
@@ -0,0 +1,159 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+
+	monitoringv1alpha1 "github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+	"github.com/kanzifucius/promethues-operator-slos/pkg/slo"
+	promoperator "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheuses,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// errPrometheusNotFound is wrapped by the FieldError returned for a
+// prometheusRef that names a Prometheus which does not exist
+var errPrometheusNotFound = goerrors.New("not found")
+
+// prometheusOptions returns the generator options and the rule labels that make
+// the Prometheus referenced by the Slo load its rules. A Prometheus without a
+// ruleNamespaceSelector only loads rules from its own namespace, the rules are
+// written there unless the Slo sets another spec.ruleNamespace, which is invalid.
+// Otherwise the namespace the rules are written to must match the selector.
+func (r *SloReconciler) prometheusOptions(ctx context.Context, sloDefinition *monitoringv1alpha1.Slo) (slo.Options, map[string]string, error) {
+	options := r.Options
+	ref := sloDefinition.Spec.PrometheusRef
+	if ref == nil {
+		return options, nil, nil
+	}
+
+	refPath := field.NewPath("spec", "prometheusRef")
+	namespace := ref.Namespace
+	if namespace == "" {
+		namespace = sloDefinition.Namespace
+	}
+
+	prometheus := &promoperator.Prometheus{}
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: namespace}, prometheus); err != nil {
+		if errors.IsNotFound(err) {
+			return options, nil, &slo.FieldError{Field: refPath, Err: fmt.Errorf("Prometheus %s/%s %w", namespace, ref.Name, errPrometheusNotFound)}
+		}
+		return options, nil, err
+	}
+
+	if prometheus.Spec.RuleNamespaceSelector == nil {
+		if sloDefinition.Spec.RuleNamespace != "" && sloDefinition.Spec.RuleNamespace != namespace {
+			return options, nil, &slo.FieldError{
+				Field: field.NewPath("spec", "ruleNamespace"),
+				Err:   fmt.Errorf("Prometheus %s/%s only loads rules from its own namespace", namespace, ref.Name),
+			}
+		}
+		options.DefaultRuleNamespace = namespace
+	} else if err := r.checkRuleNamespaceSelector(ctx, prometheus, options.RuleNamespace(sloDefinition), sloDefinition); err != nil {
+		return options, nil, err
+	}
+
+	selectorLabels, err := slo.SelectorLabels(prometheus.Spec.RuleSelector, sloDefinition.Spec.RuleLabels)
+	if err != nil {
+		return options, nil, &slo.FieldError{Field: field.NewPath("spec", "ruleLabels"), Err: err}
+	}
+	return options, selectorLabels, nil
+}
+
+// checkRuleNamespaceSelector returns a FieldError when the ruleNamespaceSelector
+// of the Prometheus does not select the namespace the rules are written to
+func (r *SloReconciler) checkRuleNamespaceSelector(ctx context.Context, prometheus *promoperator.Prometheus, ruleNamespace string, sloDefinition *monitoringv1alpha1.Slo) error {
+	selector, err := metav1.LabelSelectorAsSelector(prometheus.Spec.RuleNamespaceSelector)
+	if err != nil {
+		return fmt.Errorf("invalid ruleNamespaceSelector of Prometheus %s/%s: %v", prometheus.Namespace, prometheus.Name, err)
+	}
+
+	path := field.NewPath("spec", "ruleNamespace")
+	if sloDefinition.Spec.RuleNamespace == "" {
+		path = field.NewPath("spec", "prometheusRef")
+	}
+
+	namespace := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: ruleNamespace}, namespace); err != nil {
+		if errors.IsNotFound(err) {
+			return &slo.FieldError{Field: path, Err: fmt.Errorf("namespace %s does not exist", ruleNamespace)}
+		}
+		return err
+	}
+	if selector.Matches(labels.Set(namespace.Labels)) {
+		return nil
+	}
+
+	return &slo.FieldError{
+		Field: path,
+		Err:   fmt.Errorf("Prometheus %s/%s does not load rules from namespace %s, its ruleNamespaceSelector does not match it", prometheus.Namespace, prometheus.Name, ruleNamespace),
+	}
+}
+
+// ValidatePrometheusRef checks at admission that the Prometheus referenced by
+// the Slo loads its rules. A Prometheus that does not exist yet is left to the
+// reconciler, which reports it in the status once the Slo is created.
+func (r *SloReconciler) ValidatePrometheusRef(sloDefinition *monitoringv1alpha1.Slo) field.ErrorList {
+	_, _, err := r.prometheusOptions(context.Background(), sloDefinition)
+	if err == nil || goerrors.Is(err, errPrometheusNotFound) {
+		return nil
+	}
+
+	var fieldErr *slo.FieldError
+	if goerrors.As(err, &fieldErr) {
+		return field.ErrorList{field.Forbidden(fieldErr.Field, fieldErr.Err.Error())}
+	}
+	return field.ErrorList{field.InternalError(field.NewPath("spec", "prometheusRef"), err)}
+}
+
+// slosForPrometheus maps a Prometheus to the Slos that reference it, so that
+// their rule labels follow changes of its ruleSelector
+func (r *SloReconciler) slosForPrometheus(object handler.MapObject) []reconcile.Request {
+	sloList := &monitoringv1alpha1.SloList{}
+	if err := r.List(context.Background(), sloList); err != nil {
+		r.Log.Error(err, "Failed to list Slos")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, sloDefinition := range sloList.Items {
+		ref := sloDefinition.Spec.PrometheusRef
+		if ref == nil || ref.Name != object.Meta.GetName() {
+			continue
+		}
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = sloDefinition.Namespace
+		}
+		if namespace == object.Meta.GetNamespace() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: sloDefinition.Name, Namespace: sloDefinition.Namespace}})
+		}
+	}
+	return requests
+}
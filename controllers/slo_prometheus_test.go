@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	monitoringv1alpha1 "github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+	promoperator "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func prometheusReconciler(objects ...runtime.Object) *SloReconciler {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = promoperator.AddToScheme(scheme)
+	return &SloReconciler{Client: fake.NewFakeClientWithScheme(scheme, objects...)}
+}
+
+func prometheusSlo(ruleNamespace string, ruleLabels map[string]string) *monitoringv1alpha1.Slo {
+	return &monitoringv1alpha1.Slo{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-service", Namespace: "test-ns"},
+		Spec: monitoringv1alpha1.SloSpec{
+			Objectives:    monitoringv1alpha1.Objectives{Availability: "99.9"},
+			PrometheusRef: &monitoringv1alpha1.PrometheusReference{Name: "k8s", Namespace: "monitoring"},
+			RuleNamespace: ruleNamespace,
+			RuleLabels:    ruleLabels,
+		},
+	}
+}
+
+func TestValidatePrometheusRef(t *testing.T) {
+	namespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	prometheus := func(ruleNamespaceSelector *metav1.LabelSelector) *promoperator.Prometheus {
+		return &promoperator.Prometheus{
+			ObjectMeta: metav1.ObjectMeta{Name: "k8s", Namespace: "monitoring"},
+			Spec: promoperator.PrometheusSpec{
+				RuleNamespaceSelector: ruleNamespaceSelector,
+				RuleSelector:          &metav1.LabelSelector{MatchLabels: map[string]string{"role": "alert-rules"}},
+			},
+		}
+	}
+	monitored := &metav1.LabelSelector{MatchLabels: map[string]string{"monitoring": "enabled"}}
+	namespaces := []runtime.Object{
+		namespace("monitoring", nil),
+		namespace("test-ns", map[string]string{"monitoring": "enabled"}),
+		namespace("other-ns", nil),
+	}
+
+	tests := []struct {
+		name       string
+		prometheus *promoperator.Prometheus
+		slo        *monitoringv1alpha1.Slo
+		field      string
+	}{
+		{"own namespace", prometheus(nil), prometheusSlo("", nil), ""},
+		{"other namespace without selector", prometheus(nil), prometheusSlo("test-ns", nil), "spec.ruleNamespace"},
+		{"selected namespace", prometheus(monitored), prometheusSlo("", nil), ""},
+		{"namespace not selected", prometheus(monitored), prometheusSlo("other-ns", nil), "spec.ruleNamespace"},
+		{"namespace of the Slo not selected", prometheus(&metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}), prometheusSlo("", nil), "spec.prometheusRef"},
+		{"all namespaces", prometheus(&metav1.LabelSelector{}), prometheusSlo("other-ns", nil), ""},
+		{"missing namespace", prometheus(&metav1.LabelSelector{}), prometheusSlo("absent-ns", nil), "spec.ruleNamespace"},
+		{"conflicting rule labels", prometheus(nil), prometheusSlo("", map[string]string{"role": "recording-rules"}), "spec.ruleLabels"},
+		{"matching rule labels", prometheus(nil), prometheusSlo("", map[string]string{"role": "alert-rules"}), ""},
+		{"missing Prometheus", nil, prometheusSlo("test-ns", nil), ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objects := append([]runtime.Object{}, namespaces...)
+			if test.prometheus != nil {
+				objects = append(objects, test.prometheus)
+			}
+			errs := prometheusReconciler(objects...).ValidatePrometheusRef(test.slo)
+			if test.field == "" {
+				assert.Empty(t, errs)
+			} else if assert.Len(t, errs, 1) {
+				assert.Equal(t, test.field, errs[0].Field)
+			}
+		})
+	}
+}
+
+func TestPrometheusOptionsWithRuleNamespaceSelector(t *testing.T) {
+	r := prometheusReconciler(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-ns"}},
+		&promoperator.Prometheus{
+			ObjectMeta: metav1.ObjectMeta{Name: "k8s", Namespace: "monitoring"},
+			Spec:       promoperator.PrometheusSpec{RuleNamespaceSelector: &metav1.LabelSelector{}},
+		},
+	)
+	options, selectorLabels, err := r.prometheusOptions(context.Background(), prometheusSlo("", nil))
+	if assert.NoError(t, err) {
+		assert.Empty(t, options.DefaultRuleNamespace, "a Prometheus with a ruleNamespaceSelector keeps the default rule namespace")
+		assert.Empty(t, selectorLabels)
+	}
+}
@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	promoperator "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	monitoringv1alpha1 "github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+	"github.com/kanzifucius/promethues-operator-slos/pkg/slo"
+)
+
+const (
+	timeout  = 10 * time.Second
+	interval = 250 * time.Millisecond
+)
+
+var _ = Describe("Slo controller", func() {
+	ctx := context.Background()
+	var namespace string
+	var namespaceCount int
+
+	createNamespace := func(name string) {
+		err := k8sClient.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+		if !apierrors.IsAlreadyExists(err) {
+			Expect(err).ToNot(HaveOccurred())
+		}
+	}
+
+	newSlo := func() *monitoringv1alpha1.Slo {
+		return &monitoringv1alpha1.Slo{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-service", Namespace: namespace},
+			Spec: monitoringv1alpha1.SloSpec{
+				Objectives: monitoringv1alpha1.Objectives{Availability: "99.9"},
+				ErrorRateRecord: monitoringv1alpha1.ExprBlock{
+					Expr: "sum(rate(http_requests_total{status=\"5xx\"}[$window])) / sum(rate(http_requests_total[$window]))",
+				},
+			},
+		}
+	}
+
+	getRule := func(namespace, name string) func() (*promoperator.PrometheusRule, error) {
+		return func() (*promoperator.PrometheusRule, error) {
+			rule := &promoperator.PrometheusRule{}
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, rule)
+			return rule, err
+		}
+	}
+
+	ruleGone := func(namespace, name string) func() bool {
+		return func() bool {
+			_, err := getRule(namespace, name)()
+			return apierrors.IsNotFound(err)
+		}
+	}
+
+	finalizers := func(sloDefinition *monitoringv1alpha1.Slo) func() []string {
+		return func() []string {
+			current := &monitoringv1alpha1.Slo{}
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: sloDefinition.Name, Namespace: sloDefinition.Namespace}, current); err != nil {
+				return nil
+			}
+			return current.Finalizers
+		}
+	}
+
+	sloGone := func(sloDefinition *monitoringv1alpha1.Slo) func() bool {
+		return func() bool {
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: sloDefinition.Name, Namespace: sloDefinition.Namespace}, &monitoringv1alpha1.Slo{})
+			return apierrors.IsNotFound(err)
+		}
+	}
+
+	readyCondition := func(sloDefinition *monitoringv1alpha1.Slo) func() *monitoringv1alpha1.SloCondition {
+		return func() *monitoringv1alpha1.SloCondition {
+			current := &monitoringv1alpha1.Slo{}
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: sloDefinition.Name, Namespace: sloDefinition.Namespace}, current); err != nil {
+				return nil
+			}
+			return current.Status.GetCondition(monitoringv1alpha1.SloReady)
+		}
+	}
+
+	BeforeEach(func() {
+		// namespaces are never removed by envtest, each spec gets its own
+		namespaceCount++
+		namespace = fmt.Sprintf("slo-test-%d", namespaceCount)
+		createNamespace(namespace)
+		createNamespace(ruleNamespace)
+	})
+
+	It("creates the rule, adds the finalizer and reports it as ready", func() {
+		sloDefinition := newSlo()
+		Expect(k8sClient.Create(ctx, sloDefinition)).To(Succeed())
+
+		var rule *promoperator.PrometheusRule
+		Eventually(func() error {
+			var err error
+			rule, err = getRule(namespace, "test-service")()
+			return err
+		}, timeout, interval).Should(Succeed())
+		Expect(rule.Labels).To(HaveKeyWithValue(slo.LabelSloName, "test-service"))
+		Expect(rule.Labels).To(HaveKeyWithValue(slo.LabelSloNamespace, namespace))
+		Expect(rule.OwnerReferences).To(HaveLen(1))
+		Expect(rule.Spec.Groups).ToNot(BeEmpty())
+
+		Eventually(finalizers(sloDefinition), timeout, interval).Should(ContainElement(sloFinalizer))
+
+		Eventually(readyCondition(sloDefinition), timeout, interval).Should(And(
+			Not(BeNil()),
+			WithTransform(func(c *monitoringv1alpha1.SloCondition) corev1.ConditionStatus { return c.Status }, Equal(corev1.ConditionTrue)),
+		))
+	})
+
+	It("moves the rule to a new rule namespace and deletes the stale one", func() {
+		sloDefinition := newSlo()
+		Expect(k8sClient.Create(ctx, sloDefinition)).To(Succeed())
+		Eventually(func() error {
+			_, err := getRule(namespace, "test-service")()
+			return err
+		}, timeout, interval).Should(Succeed())
+
+		Eventually(func() error {
+			current := &monitoringv1alpha1.Slo{}
+			if err := k8sClient.Get(ctx, types.NamespacedName{Name: "test-service", Namespace: namespace}, current); err != nil {
+				return err
+			}
+			current.Spec.RuleNamespace = ruleNamespace
+			return k8sClient.Update(ctx, current)
+		}, timeout, interval).Should(Succeed())
+
+		movedName := namespace + "-test-service"
+		Eventually(func() error {
+			_, err := getRule(ruleNamespace, movedName)()
+			return err
+		}, timeout, interval).Should(Succeed())
+		Eventually(ruleGone(namespace, "test-service"), timeout, interval).Should(BeTrue())
+	})
+
+	It("deletes its rules in other namespaces when the Slo is deleted", func() {
+		sloDefinition := newSlo()
+		sloDefinition.Spec.RuleNamespace = ruleNamespace
+		Expect(k8sClient.Create(ctx, sloDefinition)).To(Succeed())
+
+		movedName := namespace + "-test-service"
+		Eventually(func() error {
+			_, err := getRule(ruleNamespace, movedName)()
+			return err
+		}, timeout, interval).Should(Succeed())
+		Eventually(finalizers(sloDefinition), timeout, interval).Should(ContainElement(sloFinalizer))
+
+		Expect(k8sClient.Delete(ctx, sloDefinition)).To(Succeed())
+		Eventually(ruleGone(ruleNamespace, movedName), timeout, interval).Should(BeTrue())
+		Eventually(sloGone(sloDefinition), timeout, interval).Should(BeTrue())
+	})
+
+	It("refuses to overwrite a rule it does not manage", func() {
+		foreign := &promoperator.PrometheusRule{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-service", Namespace: namespace, Labels: map[string]string{"team": "a"}},
+			Spec: promoperator.PrometheusRuleSpec{Groups: []promoperator.RuleGroup{{
+				Name:  "foreign",
+				Rules: []promoperator.Rule{{Record: "foreign:up"}},
+			}}},
+		}
+		Expect(k8sClient.Create(ctx, foreign)).To(Succeed())
+
+		sloDefinition := newSlo()
+		Expect(k8sClient.Create(ctx, sloDefinition)).To(Succeed())
+
+		Eventually(readyCondition(sloDefinition), timeout, interval).Should(And(
+			Not(BeNil()),
+			WithTransform(func(c *monitoringv1alpha1.SloCondition) string { return c.Reason }, Equal(reasonApplyFailed)),
+		))
+		Consistently(func() ([]promoperator.RuleGroup, error) {
+			rule, err := getRule(namespace, "test-service")()
+			if err != nil {
+				return nil, err
+			}
+			return rule.Spec.Groups, nil
+		}, time.Second, interval).Should(Equal(foreign.Spec.Groups))
+
+		Expect(k8sClient.Delete(ctx, sloDefinition)).To(Succeed())
+		Eventually(sloGone(sloDefinition), timeout, interval).Should(BeTrue())
+		_, err := getRule(namespace, "test-service")()
+		Expect(err).ToNot(HaveOccurred(), "the finalizer leaves rules of other owners alone")
+	})
+
+	It("reverts manual edits of the labels and the spec of the rule", func() {
+		sloDefinition := newSlo()
+		sloDefinition.Labels = map[string]string{"team": "a"}
+		Expect(k8sClient.Create(ctx, sloDefinition)).To(Succeed())
+
+		var generated *promoperator.PrometheusRule
+		Eventually(func() error {
+			var err error
+			generated, err = getRule(namespace, "test-service")()
+			return err
+		}, timeout, interval).Should(Succeed())
+		Expect(generated.Labels).To(HaveKeyWithValue("team", "a"))
+
+		Eventually(func() error {
+			rule, err := getRule(namespace, "test-service")()
+			if err != nil {
+				return err
+			}
+			rule.Labels["team"] = "b"
+			rule.Labels["owner"] = "someone"
+			rule.Spec.Groups = rule.Spec.Groups[:1]
+			return k8sClient.Update(ctx, rule)
+		}, timeout, interval).Should(Succeed())
+
+		Eventually(func() (map[string]string, error) {
+			rule, err := getRule(namespace, "test-service")()
+			return rule.Labels, err
+		}, timeout, interval).Should(And(HaveKeyWithValue("team", "a"), HaveKeyWithValue("owner", "someone")),
+			"the label set by the operator is restored and the label added by hand is kept")
+		Eventually(func() ([]promoperator.RuleGroup, error) {
+			rule, err := getRule(namespace, "test-service")()
+			return rule.Spec.Groups, err
+		}, timeout, interval).Should(Equal(generated.Spec.Groups))
+	})
+
+	It("takes over the fields of rules written with Update by earlier versions", func() {
+		sloDefinition := newSlo()
+		legacy := &promoperator.PrometheusRule{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-service",
+				Namespace: namespace,
+				Labels: map[string]string{
+					slo.LabelSloName:      "test-service",
+					slo.LabelSloNamespace: namespace,
+					"removed":             "label",
+				},
+			},
+			Spec: promoperator.PrometheusRuleSpec{Groups: []promoperator.RuleGroup{{
+				Name:  "removed",
+				Rules: []promoperator.Rule{{Record: "removed:up"}},
+			}}},
+		}
+		Expect(k8sClient.Create(ctx, legacy, client.FieldOwner(legacyFieldManager))).To(Succeed())
+		Expect(k8sClient.Create(ctx, sloDefinition)).To(Succeed())
+
+		Eventually(func() (map[string]string, error) {
+			rule, err := getRule(namespace, "test-service")()
+			return rule.Labels, err
+		}, timeout, interval).ShouldNot(HaveKey("removed"))
+		rule, err := getRule(namespace, "test-service")()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rule.Spec.Groups).ToNot(ContainElement(legacy.Spec.Groups[0]))
+		for _, entry := range rule.ManagedFields {
+			Expect(entry.Manager).ToNot(Equal(legacyFieldManager))
+		}
+	})
+})
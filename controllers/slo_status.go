@@ -0,0 +1,206 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	monitoringv1alpha1 "github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+	"github.com/kanzifucius/promethues-operator-slos/pkg/slo"
+	promoperator "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	reasonRuleApplied      = "RuleApplied"
+	reasonGenerationFailed = "GenerationFailed"
+	reasonApplyFailed      = "ApplyFailed"
+	reasonStaleRule        = "StaleRule"
+	reasonSharedRecords    = "SharedRecordNames"
+	reasonUniqueRecords    = "UniqueRecordNames"
+	reasonInvalidRule      = "InvalidRule"
+	reasonInvalidField     = "InvalidField"
+	reasonValidationFailed = "ValidationFailed"
+	reasonRuleCreated      = "RuleCreated"
+	reasonRuleUpdated      = "RuleUpdated"
+	reasonRuleDeleted      = "RuleDeleted"
+	reasonCleanupFinished  = "CleanupFinished"
+)
+
+func setReady(sloDefinition *monitoringv1alpha1.Slo, rule *promoperator.PrometheusRule) {
+	status := &sloDefinition.Status
+	status.ObservedGeneration = sloDefinition.Generation
+	status.PrometheusRule = &monitoringv1alpha1.RuleReference{Name: rule.Name, Namespace: rule.Namespace}
+	status.RecordingRules = slo.RecordingRuleNames(rule)
+	status.InvalidRules = nil
+
+	setCondition(sloDefinition, monitoringv1alpha1.SloReady, corev1.ConditionTrue, reasonRuleApplied, "PrometheusRule is up to date")
+	setCondition(sloDefinition, monitoringv1alpha1.SloDegraded, corev1.ConditionFalse, reasonRuleApplied, "")
+	setCondition(sloDefinition, monitoringv1alpha1.SloGenerationFailed, corev1.ConditionFalse, reasonRuleApplied, "")
+}
+
+// generationFailedReason returns the reason of the conditions and event of a
+// generation error, InvalidField when a field of the spec caused it
+func generationFailedReason(err error) string {
+	if apierrors.IsInvalid(err) {
+		return reasonValidationFailed
+	}
+	var fieldErr *slo.FieldError
+	if errors.As(err, &fieldErr) {
+		return reasonInvalidField
+	}
+	var invalid *slo.InvalidRulesError
+	if errors.As(err, &invalid) {
+		return reasonInvalidRule
+	}
+	return reasonGenerationFailed
+}
+
+// setGenerationFailed records a spec that could not be turned into rules. Any
+// PrometheusRule generated from an earlier generation is left in place, which
+// makes the Slo degraded rather than simply not ready.
+func setGenerationFailed(sloDefinition *monitoringv1alpha1.Slo, err error, ruleExists bool) {
+	sloDefinition.Status.ObservedGeneration = sloDefinition.Generation
+
+	reason := generationFailedReason(err)
+	setCondition(sloDefinition, monitoringv1alpha1.SloReady, corev1.ConditionFalse, reason, err.Error())
+	setCondition(sloDefinition, monitoringv1alpha1.SloGenerationFailed, corev1.ConditionTrue, reason, err.Error())
+	if ruleExists {
+		setCondition(sloDefinition, monitoringv1alpha1.SloDegraded, corev1.ConditionTrue, reasonStaleRule, "PrometheusRule was generated from an earlier version of the spec")
+	} else {
+		setCondition(sloDefinition, monitoringv1alpha1.SloDegraded, corev1.ConditionFalse, reasonGenerationFailed, "")
+	}
+}
+
+// setInvalidRules records the generated rules that failed to parse, replacing
+// those of an earlier generation. A nil invalid clears them.
+func setInvalidRules(sloDefinition *monitoringv1alpha1.Slo, invalid *slo.InvalidRulesError) {
+	sloDefinition.Status.InvalidRules = nil
+	if invalid == nil {
+		return
+	}
+	for _, rule := range invalid.Rules {
+		sloDefinition.Status.InvalidRules = append(sloDefinition.Status.InvalidRules, monitoringv1alpha1.InvalidRule{
+			Group: rule.Group,
+			Rule:  rule.Rule,
+			Expr:  rule.Expr,
+			Error: rule.Err.Error(),
+		})
+	}
+}
+
+func setApplyFailed(sloDefinition *monitoringv1alpha1.Slo, err error) {
+	sloDefinition.Status.ObservedGeneration = sloDefinition.Generation
+
+	setCondition(sloDefinition, monitoringv1alpha1.SloReady, corev1.ConditionFalse, reasonApplyFailed, err.Error())
+	setCondition(sloDefinition, monitoringv1alpha1.SloDegraded, corev1.ConditionTrue, reasonApplyFailed, err.Error())
+	setCondition(sloDefinition, monitoringv1alpha1.SloGenerationFailed, corev1.ConditionFalse, reasonApplyFailed, "")
+}
+
+// setNameConflict records the other Slos whose records have the same names. The
+// series are still told apart by their slo_name and slo_namespace labels, but
+// queries on the record name alone mix them up.
+func setNameConflict(sloDefinition *monitoringv1alpha1.Slo, conflicts []string) {
+	if len(conflicts) == 0 {
+		setCondition(sloDefinition, monitoringv1alpha1.SloNameConflict, corev1.ConditionFalse, reasonUniqueRecords, "")
+		return
+	}
+
+	message := fmt.Sprintf("record names are shared with %s, use the %s naming strategy to keep them apart",
+		strings.Join(conflicts, ", "), slo.NamingNamespaceName)
+	setCondition(sloDefinition, monitoringv1alpha1.SloNameConflict, corev1.ConditionTrue, reasonSharedRecords, message)
+}
+
+func setCondition(sloDefinition *monitoringv1alpha1.Slo, conditionType monitoringv1alpha1.SloConditionType, status corev1.ConditionStatus, reason, message string) {
+	sloDefinition.Status.SetCondition(monitoringv1alpha1.SloCondition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: sloDefinition.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// setSummary copies the spec values and the Ready condition shown in the
+// printer columns into the status
+func setSummary(sloDefinition *monitoringv1alpha1.Slo) {
+	status := &sloDefinition.Status
+	status.Objective = sloDefinition.Spec.Objectives.Availability
+	status.Window = sloDefinition.Spec.Objectives.Window
+	if status.Window == "" {
+		status.Window = monitoringv1alpha1.DefaultObjectivesWindow
+	}
+
+	var methods []string
+	for _, method := range []string{sloDefinition.Spec.ErrorRateRecord.AlertMethod, sloDefinition.Spec.LatencyRecord.AlertMethod} {
+		if method != "" && !contains(methods, method) {
+			methods = append(methods, method)
+		}
+	}
+	status.AlertMethods = strings.Join(methods, ",")
+
+	status.Ready = corev1.ConditionUnknown
+	if ready := status.GetCondition(monitoringv1alpha1.SloReady); ready != nil {
+		status.Ready = ready.Status
+	}
+
+	status.RuleName = ""
+	if status.PrometheusRule != nil {
+		status.RuleName = status.PrometheusRule.Name
+	}
+
+	status.BudgetRemaining = ""
+	if status.Live != nil {
+		status.BudgetRemaining = status.Live.BudgetRemaining
+	}
+}
+
+// setLiveValues formats the values read from Prometheus, values without series
+// are cleared
+func setLiveValues(live *monitoringv1alpha1.LiveStatus, values slo.LiveValues) {
+	live.SLI = formatLiveValue(values.SLI, "%.4g")
+	live.BudgetRemaining = formatLiveValue(values.BudgetRemaining, "%.3g")
+	live.PageBurnRate = ""
+	if burnRate, ok := values.BurnRates["page"]; ok {
+		live.PageBurnRate = fmt.Sprintf("%.3g", burnRate)
+	}
+	live.TicketBurnRate = ""
+	if burnRate, ok := values.BurnRates["ticket"]; ok {
+		live.TicketBurnRate = fmt.Sprintf("%.3g", burnRate)
+	}
+}
+
+func formatLiveValue(value *float64, format string) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprintf(format, *value)
+}
+
+func (r *SloReconciler) updateStatus(ctx context.Context, log logr.Logger, sloDefinition *monitoringv1alpha1.Slo) error {
+	setSummary(sloDefinition)
+	err := r.Status().Update(ctx, sloDefinition)
+	if err != nil {
+		log.Error(err, "Failed to update Slo status")
+	}
+	return err
+}
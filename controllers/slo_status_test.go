@@ -0,0 +1,320 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	monitoringv1alpha1 "github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+	"github.com/kanzifucius/promethues-operator-slos/pkg/slo"
+	promoperator "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func statusSlo(generation int64) *monitoringv1alpha1.Slo {
+	return &monitoringv1alpha1.Slo{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-service", Namespace: "test-ns", Generation: generation},
+		Spec: monitoringv1alpha1.SloSpec{
+			Objectives: monitoringv1alpha1.Objectives{Availability: "99.9"},
+			ErrorRateRecord: monitoringv1alpha1.ExprBlock{
+				AlertMethod: monitoringv1alpha1.DefaultAlertMethod,
+			},
+		},
+	}
+}
+
+func statusRule() *promoperator.PrometheusRule {
+	return &promoperator.PrometheusRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-service", Namespace: "monitoring"},
+		Spec: promoperator.PrometheusRuleSpec{Groups: []promoperator.RuleGroup{{
+			Name: "test-service.rules",
+			Rules: []promoperator.Rule{
+				{Record: "slo:test-service:service_errors_total:ratio_rate_1h", Expr: intstr.FromString("vector(0)")},
+				{Alert: "TestServiceErrorBudgetBurn", Expr: intstr.FromString("vector(0)")},
+			},
+		}}},
+	}
+}
+
+// conditionStatuses returns the status and reason of each condition by type
+func conditionStatuses(sloDefinition *monitoringv1alpha1.Slo) map[monitoringv1alpha1.SloConditionType]string {
+	statuses := map[monitoringv1alpha1.SloConditionType]string{}
+	for _, condition := range sloDefinition.Status.Conditions {
+		statuses[condition.Type] = string(condition.Status) + "/" + condition.Reason
+	}
+	return statuses
+}
+
+func TestConditionTransitions(t *testing.T) {
+	invalid := apierrors.NewInvalid(schema.GroupKind{Group: "monitoring.kanzifucius.com", Kind: "Slo"}, "test-service",
+		field.ErrorList{field.Invalid(field.NewPath("spec", "objectives", "availability"), "high", "must be a number")})
+
+	tests := []struct {
+		name     string
+		set      func(*monitoringv1alpha1.Slo)
+		expected map[monitoringv1alpha1.SloConditionType]string
+	}{
+		{
+			name: "ready",
+			set:  func(s *monitoringv1alpha1.Slo) { setReady(s, statusRule()) },
+			expected: map[monitoringv1alpha1.SloConditionType]string{
+				monitoringv1alpha1.SloReady:            "True/RuleApplied",
+				monitoringv1alpha1.SloDegraded:         "False/RuleApplied",
+				monitoringv1alpha1.SloGenerationFailed: "False/RuleApplied",
+			},
+		},
+		{
+			name: "generation failed without a rule",
+			set:  func(s *monitoringv1alpha1.Slo) { setGenerationFailed(s, errors.New("boom"), false) },
+			expected: map[monitoringv1alpha1.SloConditionType]string{
+				monitoringv1alpha1.SloReady:            "False/GenerationFailed",
+				monitoringv1alpha1.SloDegraded:         "False/GenerationFailed",
+				monitoringv1alpha1.SloGenerationFailed: "True/GenerationFailed",
+			},
+		},
+		{
+			name: "generation failed with a rule of an earlier generation",
+			set:  func(s *monitoringv1alpha1.Slo) { setGenerationFailed(s, errors.New("boom"), true) },
+			expected: map[monitoringv1alpha1.SloConditionType]string{
+				monitoringv1alpha1.SloReady:            "False/GenerationFailed",
+				monitoringv1alpha1.SloDegraded:         "True/StaleRule",
+				monitoringv1alpha1.SloGenerationFailed: "True/GenerationFailed",
+			},
+		},
+		{
+			name: "invalid field",
+			set: func(s *monitoringv1alpha1.Slo) {
+				setGenerationFailed(s, &slo.FieldError{Field: field.NewPath("spec", "errorRateRecord", "alertMethod"), Err: slo.ErrUnknownAlertMethod}, false)
+			},
+			expected: map[monitoringv1alpha1.SloConditionType]string{
+				monitoringv1alpha1.SloReady:            "False/InvalidField",
+				monitoringv1alpha1.SloDegraded:         "False/GenerationFailed",
+				monitoringv1alpha1.SloGenerationFailed: "True/InvalidField",
+			},
+		},
+		{
+			name: "validation failed",
+			set:  func(s *monitoringv1alpha1.Slo) { setGenerationFailed(s, invalid, false) },
+			expected: map[monitoringv1alpha1.SloConditionType]string{
+				monitoringv1alpha1.SloReady:            "False/ValidationFailed",
+				monitoringv1alpha1.SloDegraded:         "False/GenerationFailed",
+				monitoringv1alpha1.SloGenerationFailed: "True/ValidationFailed",
+			},
+		},
+		{
+			name: "apply failed",
+			set:  func(s *monitoringv1alpha1.Slo) { setApplyFailed(s, errors.New("forbidden")) },
+			expected: map[monitoringv1alpha1.SloConditionType]string{
+				monitoringv1alpha1.SloReady:            "False/ApplyFailed",
+				monitoringv1alpha1.SloDegraded:         "True/ApplyFailed",
+				monitoringv1alpha1.SloGenerationFailed: "False/ApplyFailed",
+			},
+		},
+		{
+			name: "ready after a failed apply",
+			set: func(s *monitoringv1alpha1.Slo) {
+				setApplyFailed(s, errors.New("forbidden"))
+				setReady(s, statusRule())
+			},
+			expected: map[monitoringv1alpha1.SloConditionType]string{
+				monitoringv1alpha1.SloReady:            "True/RuleApplied",
+				monitoringv1alpha1.SloDegraded:         "False/RuleApplied",
+				monitoringv1alpha1.SloGenerationFailed: "False/RuleApplied",
+			},
+		},
+		{
+			name: "name conflict",
+			set:  func(s *monitoringv1alpha1.Slo) { setNameConflict(s, []string{"other-ns/test-service"}) },
+			expected: map[monitoringv1alpha1.SloConditionType]string{
+				monitoringv1alpha1.SloNameConflict: "True/SharedRecordNames",
+			},
+		},
+		{
+			name: "name conflict resolved",
+			set: func(s *monitoringv1alpha1.Slo) {
+				setNameConflict(s, []string{"other-ns/test-service"})
+				setNameConflict(s, nil)
+			},
+			expected: map[monitoringv1alpha1.SloConditionType]string{
+				monitoringv1alpha1.SloNameConflict: "False/UniqueRecordNames",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sloDefinition := statusSlo(3)
+			test.set(sloDefinition)
+
+			assert.Equal(t, test.expected, conditionStatuses(sloDefinition))
+			for _, condition := range sloDefinition.Status.Conditions {
+				assert.Equal(t, int64(3), condition.ObservedGeneration, condition.Type)
+				assert.False(t, condition.LastTransitionTime.IsZero(), condition.Type)
+			}
+		})
+	}
+}
+
+func TestObservedGeneration(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(*monitoringv1alpha1.Slo)
+	}{
+		{"ready", func(s *monitoringv1alpha1.Slo) { setReady(s, statusRule()) }},
+		{"generation failed", func(s *monitoringv1alpha1.Slo) { setGenerationFailed(s, errors.New("boom"), true) }},
+		{"apply failed", func(s *monitoringv1alpha1.Slo) { setApplyFailed(s, errors.New("forbidden")) }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sloDefinition := statusSlo(1)
+			setReady(sloDefinition, statusRule())
+			assert.Equal(t, int64(1), sloDefinition.Status.ObservedGeneration)
+
+			sloDefinition.Generation = 2
+			test.set(sloDefinition)
+			assert.Equal(t, int64(2), sloDefinition.Status.ObservedGeneration)
+			for _, condition := range sloDefinition.Status.Conditions {
+				assert.Equal(t, int64(2), condition.ObservedGeneration, condition.Type)
+			}
+		})
+	}
+}
+
+func TestLastTransitionTimeIsStable(t *testing.T) {
+	sloDefinition := statusSlo(1)
+	setReady(sloDefinition, statusRule())
+	then := metav1.NewTime(time.Now().Add(-time.Hour))
+	for i := range sloDefinition.Status.Conditions {
+		sloDefinition.Status.Conditions[i].LastTransitionTime = then
+	}
+
+	sloDefinition.Generation = 2
+	setReady(sloDefinition, statusRule())
+	for _, condition := range sloDefinition.Status.Conditions {
+		assert.Equal(t, then, condition.LastTransitionTime, "status of %s did not change", condition.Type)
+	}
+
+	setApplyFailed(sloDefinition, errors.New("forbidden"))
+	assert.True(t, sloDefinition.Status.GetCondition(monitoringv1alpha1.SloReady).LastTransitionTime.After(then.Time))
+	assert.True(t, sloDefinition.Status.GetCondition(monitoringv1alpha1.SloDegraded).LastTransitionTime.After(then.Time))
+	assert.Equal(t, then, sloDefinition.Status.GetCondition(monitoringv1alpha1.SloGenerationFailed).LastTransitionTime,
+		"GenerationFailed stays false")
+	assert.Equal(t, "forbidden", sloDefinition.Status.GetCondition(monitoringv1alpha1.SloReady).Message)
+}
+
+func TestSetReady(t *testing.T) {
+	sloDefinition := statusSlo(1)
+	sloDefinition.Status.InvalidRules = []monitoringv1alpha1.InvalidRule{{Group: "g", Rule: "r", Expr: "(", Error: "parse error"}}
+
+	setReady(sloDefinition, statusRule())
+
+	assert.Equal(t, &monitoringv1alpha1.RuleReference{Name: "test-service", Namespace: "monitoring"}, sloDefinition.Status.PrometheusRule)
+	assert.Equal(t, []string{"slo:test-service:service_errors_total:ratio_rate_1h"}, sloDefinition.Status.RecordingRules)
+	assert.Nil(t, sloDefinition.Status.InvalidRules)
+}
+
+func TestSetInvalidRules(t *testing.T) {
+	sloDefinition := statusSlo(1)
+	setInvalidRules(sloDefinition, &slo.InvalidRulesError{Rules: []*slo.RuleError{
+		{Group: "test-service.rules", Rule: "slo:test-service:bad", Expr: "sum(", Err: errors.New("unexpected end of input")},
+	}})
+	assert.Equal(t, []monitoringv1alpha1.InvalidRule{{
+		Group: "test-service.rules",
+		Rule:  "slo:test-service:bad",
+		Expr:  "sum(",
+		Error: "unexpected end of input",
+	}}, sloDefinition.Status.InvalidRules)
+
+	setInvalidRules(sloDefinition, nil)
+	assert.Nil(t, sloDefinition.Status.InvalidRules)
+}
+
+func TestSetSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		set      func(*monitoringv1alpha1.Slo)
+		expected monitoringv1alpha1.SloStatus
+	}{
+		{
+			name: "before the first reconcile",
+			set:  func(s *monitoringv1alpha1.Slo) {},
+			expected: monitoringv1alpha1.SloStatus{
+				Objective:    "99.9",
+				Window:       monitoringv1alpha1.DefaultObjectivesWindow,
+				AlertMethods: monitoringv1alpha1.DefaultAlertMethod,
+				Ready:        corev1.ConditionUnknown,
+			},
+		},
+		{
+			name: "ready with live values",
+			set: func(s *monitoringv1alpha1.Slo) {
+				s.Spec.Objectives.Window = "28d"
+				s.Spec.LatencyRecord.AlertMethod = monitoringv1alpha1.BurnRateAlertMethod
+				setReady(s, statusRule())
+				s.Status.Live = &monitoringv1alpha1.LiveStatus{BudgetRemaining: "42"}
+			},
+			expected: monitoringv1alpha1.SloStatus{
+				Objective:       "99.9",
+				Window:          "28d",
+				AlertMethods:    "multi-window,burn-rate",
+				Ready:           corev1.ConditionTrue,
+				RuleName:        "test-service",
+				BudgetRemaining: "42",
+			},
+		},
+		{
+			name: "same alert method on both records",
+			set: func(s *monitoringv1alpha1.Slo) {
+				s.Spec.LatencyRecord.AlertMethod = monitoringv1alpha1.DefaultAlertMethod
+				setGenerationFailed(s, errors.New("boom"), false)
+			},
+			expected: monitoringv1alpha1.SloStatus{
+				Objective:    "99.9",
+				Window:       monitoringv1alpha1.DefaultObjectivesWindow,
+				AlertMethods: monitoringv1alpha1.DefaultAlertMethod,
+				Ready:        corev1.ConditionFalse,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sloDefinition := statusSlo(1)
+			test.set(sloDefinition)
+			setSummary(sloDefinition)
+
+			status := sloDefinition.Status
+			assert.Equal(t, test.expected.Objective, status.Objective)
+			assert.Equal(t, test.expected.Window, status.Window)
+			assert.Equal(t, test.expected.AlertMethods, status.AlertMethods)
+			assert.Equal(t, test.expected.Ready, status.Ready)
+			assert.Equal(t, test.expected.RuleName, status.RuleName)
+			assert.Equal(t, test.expected.BudgetRemaining, status.BudgetRemaining)
+		})
+	}
+}
+
+func TestSetLiveValues(t *testing.T) {
+	sli, remaining := 99.95123, 51.234
+	live := &monitoringv1alpha1.LiveStatus{PageBurnRate: "3", TicketBurnRate: "1"}
+
+	setLiveValues(live, slo.LiveValues{
+		SLI:             &sli,
+		BudgetRemaining: &remaining,
+		BurnRates:       map[string]float64{"page": 0.4567},
+	})
+	assert.Equal(t, "99.95", live.SLI)
+	assert.Equal(t, "51.2", live.BudgetRemaining)
+	assert.Equal(t, "0.457", live.PageBurnRate)
+	assert.Equal(t, "", live.TicketBurnRate)
+
+	setLiveValues(live, slo.LiveValues{})
+	assert.Equal(t, monitoringv1alpha1.LiveStatus{}, *live)
+}
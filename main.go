@@ -18,17 +18,24 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	monitoringv1alpha1 "github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+	monitoringv1beta1 "github.com/kanzifucius/promethues-operator-slos/api/v1beta1"
 	"github.com/kanzifucius/promethues-operator-slos/controllers"
+	"github.com/kanzifucius/promethues-operator-slos/pkg/slo"
 	promoperator "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	// +kubebuilder:scaffold:imports
 )
@@ -42,6 +49,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(monitoringv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(monitoringv1beta1.AddToScheme(scheme))
 	utilruntime.Must(promoperator.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
@@ -50,14 +58,105 @@ func main() {
 
 	var metricsAddr string
 	var enableLeaderElection bool
+	var defaultSamplesFile string
+	var recordNaming string
+	var prometheusURL string
+	var resyncPeriod time.Duration
+	var ruleNamespace string
+	var allowedRuleNamespaces string
+	var ruleLabels string
+	var ruleAnnotations string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&defaultSamplesFile, "default-samples-file", "",
+		"Path to a YAML list of samples used for Slos that do not define spec.samples. "+
+			"Defaults to the short, medium and daily groups.")
+	flag.StringVar(&recordNaming, "record-naming", slo.NamingName,
+		"How generated records are named, one of "+strings.Join(slo.NamingStrategies, ", ")+". "+
+			"namespace-name adds the namespace of the Slo to the record names.")
+	flag.StringVar(&prometheusURL, "prometheus-url", "",
+		"URL of the Prometheus HTTP API that evaluates the generated rules. "+
+			"When set, the current SLI, error budget and burn rates are written into the Slo status.")
+	flag.DurationVar(&resyncPeriod, "status-resync-period", time.Minute,
+		"How often the Slo status is refreshed from Prometheus.")
+	flag.StringVar(&ruleNamespace, "rule-namespace", "",
+		"Namespace the PrometheusRules are written to for Slos that do not set spec.ruleNamespace. "+
+			"Defaults to the namespace of each Slo.")
+	flag.StringVar(&allowedRuleNamespaces, "allowed-rule-namespaces", "",
+		"Comma separated namespaces that spec.ruleNamespace and spec.prometheusRef may write PrometheusRules to. "+
+			"The namespace of each Slo and --rule-namespace are always allowed.")
+	flag.StringVar(&ruleLabels, "rule-labels", "",
+		"Comma separated key=value labels set on every PrometheusRule, such as the labels of the ruleSelector of Prometheus.")
+	flag.StringVar(&ruleAnnotations, "rule-annotations", "",
+		"Comma separated key=value annotations set on every PrometheusRule.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
 
+	if !slo.IsNamingStrategy(recordNaming) {
+		setupLog.Error(fmt.Errorf("unknown naming strategy %q", recordNaming), "invalid --record-naming")
+		os.Exit(1)
+	}
+
+	if ruleNamespace != "" {
+		if msgs := validation.IsDNS1123Label(ruleNamespace); len(msgs) > 0 {
+			setupLog.Error(fmt.Errorf("%s", strings.Join(msgs, ", ")), "invalid --rule-namespace", "namespace", ruleNamespace)
+			os.Exit(1)
+		}
+	}
+
+	var allowedNamespaces []string
+	for _, namespace := range strings.Split(allowedRuleNamespaces, ",") {
+		namespace = strings.TrimSpace(namespace)
+		if namespace == "" {
+			continue
+		}
+		if msgs := validation.IsDNS1123Label(namespace); len(msgs) > 0 {
+			setupLog.Error(fmt.Errorf("%s", strings.Join(msgs, ", ")), "invalid --allowed-rule-namespaces", "namespace", namespace)
+			os.Exit(1)
+		}
+		allowedNamespaces = append(allowedNamespaces, namespace)
+	}
+
+	defaultRuleLabels, err := labels.ConvertSelectorToLabelsMap(ruleLabels)
+	if err != nil {
+		setupLog.Error(err, "invalid --rule-labels")
+		os.Exit(1)
+	}
+	defaultRuleAnnotations, err := parseAnnotations(ruleAnnotations)
+	if err != nil {
+		setupLog.Error(err, "invalid --rule-annotations")
+		os.Exit(1)
+	}
+
+	generatorOptions := slo.Options{
+		Naming:                 recordNaming,
+		DefaultRuleNamespace:   ruleNamespace,
+		AllowedRuleNamespaces:  allowedNamespaces,
+		DefaultRuleLabels:      defaultRuleLabels,
+		DefaultRuleAnnotations: defaultRuleAnnotations,
+	}
+	if defaultSamplesFile != "" {
+		samples, err := slo.ReadSamples(defaultSamplesFile)
+		if err != nil {
+			setupLog.Error(err, "unable to read default samples", "file", defaultSamplesFile)
+			os.Exit(1)
+		}
+		generatorOptions.DefaultSamples = samples
+	}
+
+	var prometheus slo.Querier
+	if prometheusURL != "" {
+		querier, err := slo.NewQuerier(prometheusURL)
+		if err != nil {
+			setupLog.Error(err, "invalid --prometheus-url", "url", prometheusURL)
+			os.Exit(1)
+		}
+		prometheus = querier
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
@@ -70,14 +169,29 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err = (&controllers.SloReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Slo"),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
+	reconciler := &controllers.SloReconciler{
+		Client:       mgr.GetClient(),
+		Log:          ctrl.Log.WithName("controllers").WithName("Slo"),
+		Scheme:       mgr.GetScheme(),
+		Options:      generatorOptions,
+		Recorder:     mgr.GetEventRecorderFor("slo-controller"),
+		Prometheus:   prometheus,
+		ResyncPeriod: resyncPeriod,
+	}
+	if err = reconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Slo")
 		os.Exit(1)
 	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&monitoringv1alpha1.Slo{}).SetupWebhookWithManager(mgr, slo.IsAlertMethod, generatorOptions.RuleNamespaceAllowed, reconciler.ValidatePrometheusRef); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Slo")
+			os.Exit(1)
+		}
+		if err = (&monitoringv1beta1.Slo{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Slo")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -86,3 +200,24 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// parseAnnotations splits comma separated key=value annotations. Unlike labels,
+// annotation values are free form, such as URLs, so only the keys are validated.
+func parseAnnotations(value string) (map[string]string, error) {
+	annotations := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid annotation %q, expected key=value", pair)
+		}
+		key := strings.TrimSpace(kv[0])
+		if msgs := validation.IsQualifiedName(key); len(msgs) > 0 {
+			return nil, fmt.Errorf("invalid annotation key %q: %s", key, strings.Join(msgs, ", "))
+		}
+		annotations[key] = kv[1]
+	}
+	return annotations, nil
+}
@@ -2,10 +2,14 @@ package slo
 
 import (
 	"fmt"
+	"github.com/ghodss/yaml"
 	monitoringv1alpha1 "github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+	"io/ioutil"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
-	"log"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -22,129 +26,319 @@ var (
 	}
 )
 
+var invalidMetricNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
 type Window struct {
 	Duration     model.Duration
 	Consumption  float64
 	Notification string
 }
 
-var quantiles = []struct {
-	name     string
-	quantile float64
-}{
-	{
-		name:     "p50",
-		quantile: 0.5,
-	},
-	{
-		name:     "p95",
-		quantile: 0.95,
-	},
-	{
-		name:     "p99",
-		quantile: 0.99,
-	},
+// Options holds the operator wide settings used when generating rules
+type Options struct {
+	// DefaultSamples are used for Slos that do not define spec.samples,
+	// monitoringv1alpha1.DefaultSamples is used when it is empty
+	DefaultSamples []monitoringv1alpha1.Sample
+	// Naming is one of NamingStrategies, NamingName when it is empty
+	Naming string
+	// DefaultRuleNamespace is the namespace PrometheusRules are written to for
+	// Slos that do not set spec.ruleNamespace, the namespace of the Slo when empty
+	DefaultRuleNamespace string
+	// AllowedRuleNamespaces are the namespaces that spec.ruleNamespace and
+	// prometheusRef may write rules to, besides the namespace of the Slo and
+	// DefaultRuleNamespace
+	AllowedRuleNamespaces []string
+	// DefaultRuleLabels and DefaultRuleAnnotations are set on every
+	// PrometheusRule, spec.ruleLabels take precedence over the labels
+	DefaultRuleLabels      map[string]string
+	DefaultRuleAnnotations map[string]string
+}
+
+// RuleNamespace returns the namespace the PrometheusRule of the Slo is written to
+func (options Options) RuleNamespace(sloDefinition *monitoringv1alpha1.Slo) string {
+	if sloDefinition.Spec.RuleNamespace != "" {
+		return sloDefinition.Spec.RuleNamespace
+	}
+	if options.DefaultRuleNamespace != "" {
+		return options.DefaultRuleNamespace
+	}
+	return sloDefinition.Namespace
+}
+
+// RuleNamespaceAllowed reports whether the PrometheusRule of a Slo in
+// sloNamespace may be written to namespace. The operator can write rules to
+// any namespace, a Slo must not be able to place rules in the namespaces of
+// other teams.
+func (options Options) RuleNamespaceAllowed(sloNamespace, namespace string) bool {
+	if namespace == sloNamespace || namespace == options.DefaultRuleNamespace {
+		return true
+	}
+	for _, allowed := range options.AllowedRuleNamespaces {
+		if namespace == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// RuleName returns the name of the PrometheusRule of the Slo. Rules written to
+// another namespace are prefixed with the namespace of the Slo, so that Slos
+// with the same name in different namespaces do not overwrite each other.
+func (options Options) RuleName(sloDefinition *monitoringv1alpha1.Slo) string {
+	if options.RuleNamespace(sloDefinition) != sloDefinition.Namespace {
+		return sloDefinition.Namespace + "-" + sloDefinition.Name
+	}
+	return sloDefinition.Name
+}
+
+// samples returns the recording groups for the Slo: its own samples, then the
+// operator default and finally the built in default
+func (options Options) samples(sloDefinition *monitoringv1alpha1.Slo) []monitoringv1alpha1.Sample {
+	if len(sloDefinition.Spec.Samples) > 0 {
+		return sloDefinition.Spec.Samples
+	}
+	if len(options.DefaultSamples) > 0 {
+		return options.DefaultSamples
+	}
+	return monitoringv1alpha1.DefaultSamples
+}
+
+// ReadSamples loads the operator default samples from a YAML or JSON list
+func ReadSamples(path string) ([]monitoringv1alpha1.Sample, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []monitoringv1alpha1.Sample
+	if err := yaml.Unmarshal(content, &samples); err != nil {
+		return nil, fmt.Errorf("failed to parse samples from %s: %w", path, err)
+	}
+
+	if errs := monitoringv1alpha1.ValidateSamples(samples, field.NewPath("samples")); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid samples in %s: %w", path, errs.ToAggregate())
+	}
+
+	return samples, nil
 }
 
-func GeneratePromRules(sloDefinition *monitoringv1alpha1.Slo) (*promoperator.PrometheusRule, error) {
+func GeneratePromRules(sloDefinition *monitoringv1alpha1.Slo, options Options) (*promoperator.PrometheusRule, error) {
 
 	var Groups []promoperator.RuleGroup
 
-	ruleGroupRules, err := generateGroupRules(sloDefinition)
+	name := options.RecordName(sloDefinition)
+
+	ruleAlerts, err := generateAlertRules(sloDefinition, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// alerts and the budget records may read windows that none of the samples
+	// record, those are recorded in additional groups so that every rule has its series
+	samples := options.samples(sloDefinition)
+	windows := append(referencedWindows(ruleAlerts), budgetWindow(sloDefinition)...)
+	samples = append(samples, alertingSamples(windows, samples)...)
+
+	ruleGroupRules, err := generateGroupRules(sloDefinition, samples, name)
 	if err != nil {
 		return nil, err
 	}
 	Groups = append(Groups, ruleGroupRules...)
 
-	ruleAlerts, err := generateAlertRules(sloDefinition)
+	metadataGroup, err := generateMetadataGroup(sloDefinition)
 	if err != nil {
 		return nil, err
 	}
+	Groups = append(Groups, metadataGroup)
+
 	Groups = append(Groups, promoperator.RuleGroup{
 		Name:  "slo:" + santizeString(sloDefinition.Name) + ":alert",
 		Rules: ruleAlerts,
 	})
 
+	if err := validateGroups(Groups); err != nil {
+		return nil, err
+	}
+
+	// later label sets take precedence
+	ruleLabels := map[string]string{}
+	for _, labelSet := range []map[string]string{sloDefinition.Labels, options.DefaultRuleLabels, sloDefinition.Spec.RuleLabels, TrackingLabels(sloDefinition)} {
+		for key, value := range labelSet {
+			ruleLabels[key] = value
+		}
+	}
+
+	var ruleAnnotations map[string]string
+	if len(options.DefaultRuleAnnotations) > 0 {
+		ruleAnnotations = map[string]string{}
+		for key, value := range options.DefaultRuleAnnotations {
+			ruleAnnotations[key] = value
+		}
+	}
+
 	prometheusRule := &promoperator.PrometheusRule{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       promoperator.PrometheusRuleKind,
-			APIVersion: " monitoring.coreos.com/v1",
+			APIVersion: promoperator.SchemeGroupVersion.String(),
 		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      sloDefinition.Name,
-			Namespace: sloDefinition.Namespace,
-			Labels:    sloDefinition.Labels,
+			Name:        options.RuleName(sloDefinition),
+			Namespace:   options.RuleNamespace(sloDefinition),
+			Labels:      ruleLabels,
+			Annotations: ruleAnnotations,
 		},
 		Spec: promoperator.PrometheusRuleSpec{Groups: Groups},
 	}
 
-	// Set Memcached instance as the owner and controller
-
 	return prometheusRule, nil
 }
 
-func generateAlertRules(sloDefinition *monitoringv1alpha1.Slo) ([]promoperator.Rule, error) {
+// RecordingRuleNames returns the distinct record names emitted into the rule, in
+// the order in which they are first generated.
+func RecordingRuleNames(rule *promoperator.PrometheusRule) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, group := range rule.Spec.Groups {
+		for _, r := range group.Rules {
+			if r.Record == "" || seen[r.Record] {
+				continue
+			}
+			seen[r.Record] = true
+			names = append(names, r.Record)
+		}
+	}
+	return names
+}
 
-	var alertRules []promoperator.Rule
+// GroupChanges lists the names of the rule groups that differ between two
+// versions of a PrometheusRule
+type GroupChanges struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
 
-	if sloDefinition.Spec.ErrorRateRecord.AlertMethod != "" {
-		errorMethod := GetAlertMethod(sloDefinition.Spec.ErrorRateRecord.AlertMethod)
-		if errorMethod == nil {
-			log.Panicf("alertMethod %s is not valid", sloDefinition.Spec.ErrorRateRecord.AlertMethod)
+// Empty reports whether the groups of both versions are the same
+func (changes GroupChanges) Empty() bool {
+	return len(changes.Added) == 0 && len(changes.Removed) == 0 && len(changes.Changed) == 0
+}
+
+func (changes GroupChanges) String() string {
+	var parts []string
+	if len(changes.Added) > 0 {
+		parts = append(parts, "added "+strings.Join(changes.Added, ", "))
+	}
+	if len(changes.Changed) > 0 {
+		parts = append(parts, "changed "+strings.Join(changes.Changed, ", "))
+	}
+	if len(changes.Removed) > 0 {
+		parts = append(parts, "removed "+strings.Join(changes.Removed, ", "))
+	}
+	if len(parts) == 0 {
+		return "no group changes"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// CompareGroups returns the groups that were added, removed or changed from
+// the previous to the current rule groups
+func CompareGroups(previous, current []promoperator.RuleGroup) GroupChanges {
+	var changes GroupChanges
+	previousGroups := map[string]promoperator.RuleGroup{}
+	for _, group := range previous {
+		previousGroups[group.Name] = group
+	}
+
+	for _, group := range current {
+		previousGroup, ok := previousGroups[group.Name]
+		if !ok {
+			changes.Added = append(changes.Added, group.Name)
+		} else if !reflect.DeepEqual(previousGroup, group) {
+			changes.Changed = append(changes.Changed, group.Name)
 		}
+		delete(previousGroups, group.Name)
+	}
 
-		var Windows []Window
-		for _, recordWindow := range sloDefinition.Spec.ErrorRateRecord.Windows {
-			recWindowDuration, err := model.ParseDuration(recordWindow.Duration)
-			if err != nil {
-				return nil, fmt.Errorf("failed to convert %s to float", recordWindow.Duration)
-			}
+	for _, group := range previous {
+		if _, ok := previousGroups[group.Name]; ok {
+			changes.Removed = append(changes.Removed, group.Name)
+		}
+	}
+	return changes
+}
 
-			recWindowConsumption, err := strconv.ParseFloat(recordWindow.Consumption, 64)
-			if err != nil {
-				return nil, fmt.Errorf("failed to convert %s to float", recordWindow.Consumption)
-			}
+func generateAlertRules(sloDefinition *monitoringv1alpha1.Slo, name string) ([]promoperator.Rule, error) {
+	var alerts []Alert
+	specPath := field.NewPath("spec")
 
-			Windows = append(Windows, Window{
+	minTrafficRate, err := parseMinRate(sloDefinition.Spec.TrafficRateRecord)
+	if err != nil {
+		return nil, fieldError(specPath.Child("trafficRateRecord", "minRate"), err)
+	}
 
-				Duration:     recWindowDuration,
-				Consumption:  recWindowConsumption,
-				Notification: recordWindow.Notification,
-			})
+	if sloDefinition.Spec.ErrorRateRecord.AlertMethod != "" {
+		recordPath := specPath.Child("errorRateRecord")
+		errorMethod, err := alertMethod(sloDefinition.Spec.ErrorRateRecord, recordPath)
+		if err != nil {
+			return nil, err
 		}
 
-		objectivesWindow, err := time.ParseDuration(sloDefinition.Spec.Objectives.Window)
+		Windows, err := parseWindows(sloDefinition.Spec.ErrorRateRecord.Windows, sloDefinition.Spec.Objectives.Window)
 		if err != nil {
-			return nil, fmt.Errorf("failed to convert %s to duration", sloDefinition.Spec.Objectives.Window)
+			return nil, fieldError(recordPath.Child("windows"), err)
 		}
 
-		errorRules, err := errorMethod.AlertForError(&AlertErrorOptions{
-			ServiceName:        santizeString(sloDefinition.Name),
+		budgetThresholds, err := parseBudgetThresholds(sloDefinition.Spec.ErrorRateRecord.BudgetThresholds)
+		if err != nil {
+			return nil, fieldError(recordPath.Child("budgetThresholds"), err)
+		}
+
+		forecast, err := parseForecast(sloDefinition.Spec.ErrorRateRecord.Forecast)
+		if err != nil {
+			return nil, fieldError(recordPath.Child("forecast"), err)
+		}
+
+		objectivesWindow, err := monitoringv1alpha1.ParseDuration(sloDefinition.Spec.Objectives.Window)
+		if err != nil {
+			return nil, fieldError(specPath.Child("objectives", "window"), fmt.Errorf("failed to convert %s to duration", sloDefinition.Spec.Objectives.Window))
+		}
+
+		if _, err := strconv.ParseFloat(sloDefinition.Spec.Objectives.Availability, 64); err != nil {
+			return nil, fieldError(specPath.Child("objectives", "availability"), fmt.Errorf("failed to convert %s to float", sloDefinition.Spec.Objectives.Availability))
+		}
+
+		errorAlerts, err := errorMethod.AlertForError(&AlertErrorOptions{
+			ServiceName:        name,
+			Selector:           identitySelector(sloDefinition),
 			AvailabilityTarget: sloDefinition.Spec.Objectives.Availability,
 			SLOWindow:          objectivesWindow,
 			ShortWindow:        sloDefinition.Spec.ErrorRateRecord.GetShortWindow(),
 			Windows:            Windows,
 			BurnRate:           sloDefinition.Spec.ErrorRateRecord.BurnRate,
+			MinTrafficRate:     minTrafficRate,
+			BudgetThresholds:   budgetThresholds,
+			Forecast:           forecast,
 		})
 		if err != nil {
-			log.Panicf("Could not generate alert, err: %s", err.Error())
+			return nil, fieldError(recordPath, fmt.Errorf("could not generate alerts: %w", err))
 		}
-		alertRules = append(alertRules, errorRules...)
+		alerts = append(alerts, errorAlerts...)
 	}
 
 	if sloDefinition.Spec.LatencyRecord.AlertMethod != "" {
-		latencyMethod := GetAlertMethod(sloDefinition.Spec.LatencyRecord.AlertMethod)
-		if latencyMethod == nil {
-			log.Panicf("alertMethod %s is not valid", sloDefinition.Spec.LatencyRecord.AlertMethod)
+		recordPath := specPath.Child("latencyRecord")
+		latencyMethod, err := alertMethod(sloDefinition.Spec.LatencyRecord, recordPath)
+		if err != nil {
+			return nil, err
 		}
 
 		if sloDefinition.Spec.Objectives.Latency != nil {
 			var LatencyTargets []LatencyTarget
-			for _, record := range sloDefinition.Spec.Objectives.Latency {
+			for i, record := range sloDefinition.Spec.Objectives.Latency {
 
 				target, err := strconv.ParseFloat(record.Target, 64)
 				if err != nil {
-					return nil, fmt.Errorf("failed to convert %s to float", record.Target)
+					return nil, fieldError(specPath.Child("objectives", "latency").Index(i).Child("target"), fmt.Errorf("failed to convert %s to float", record.Target))
 
 				}
 
@@ -154,75 +348,198 @@ func generateAlertRules(sloDefinition *monitoringv1alpha1.Slo) ([]promoperator.R
 				})
 			}
 
-			var Windows []Window
-			for _, recordWindow := range sloDefinition.Spec.LatencyRecord.Windows {
-
-				recWindowDuration, err := model.ParseDuration(recordWindow.Duration)
-				if err != nil {
-					return nil, fmt.Errorf("failed to convert %s to duration", recordWindow.Duration)
-				}
+			Windows, err := parseWindows(sloDefinition.Spec.LatencyRecord.Windows, sloDefinition.Spec.Objectives.Window)
+			if err != nil {
+				return nil, fieldError(recordPath.Child("windows"), err)
+			}
 
-				recWindowConsumption, err := strconv.ParseFloat(recordWindow.Consumption, 64)
-				if err != nil {
-					return nil, fmt.Errorf("failed to convert %s to float", recordWindow.Consumption)
-				}
+			budgetThresholds, err := parseBudgetThresholds(sloDefinition.Spec.LatencyRecord.BudgetThresholds)
+			if err != nil {
+				return nil, fieldError(recordPath.Child("budgetThresholds"), err)
+			}
 
-				Windows = append(Windows, Window{
-					Duration:     recWindowDuration,
-					Consumption:  recWindowConsumption,
-					Notification: recordWindow.Notification,
-				})
+			forecast, err := parseForecast(sloDefinition.Spec.LatencyRecord.Forecast)
+			if err != nil {
+				return nil, fieldError(recordPath.Child("forecast"), err)
 			}
 
-			objectivesWindow, err := time.ParseDuration(sloDefinition.Spec.Objectives.Window)
+			objectivesWindow, err := monitoringv1alpha1.ParseDuration(sloDefinition.Spec.Objectives.Window)
 			if err != nil {
-				return nil, fmt.Errorf("failed to convert %s to duration", sloDefinition.Spec.Objectives.Window)
+				return nil, fieldError(specPath.Child("objectives", "window"), fmt.Errorf("failed to convert %s to duration", sloDefinition.Spec.Objectives.Window))
 			}
 
-			latencyRules, err := latencyMethod.AlertForLatency(&AlertLatencyOptions{
-				ServiceName: santizeString(sloDefinition.Name),
-				Targets:     LatencyTargets,
-				SLOWindow:   objectivesWindow,
-				ShortWindow: sloDefinition.Spec.LatencyRecord.GetShortWindow(),
-				Windows:     Windows,
-				BurnRate:    sloDefinition.Spec.ErrorRateRecord.BurnRate,
+			latencyAlerts, err := latencyMethod.AlertForLatency(&AlertLatencyOptions{
+				ServiceName:      name,
+				Selector:         identitySelector(sloDefinition),
+				Targets:          LatencyTargets,
+				SLOWindow:        objectivesWindow,
+				ShortWindow:      sloDefinition.Spec.LatencyRecord.GetShortWindow(),
+				Windows:          Windows,
+				BurnRate:         sloDefinition.Spec.LatencyRecord.BurnRate,
+				MinTrafficRate:   minTrafficRate,
+				BudgetThresholds: budgetThresholds,
+				Forecast:         forecast,
 			})
 			if err != nil {
-				log.Panicf("Could not generate alert, err: %s", err.Error())
+				return nil, fieldError(recordPath, fmt.Errorf("could not generate alerts: %w", err))
 			}
-			alertRules = append(alertRules, latencyRules...)
+			alerts = append(alerts, latencyAlerts...)
 		}
 	}
 
-	for _, rule := range alertRules {
-		fillMetadata(&rule, sloDefinition)
+	var alertRules []promoperator.Rule
+	for i := range alerts {
+		if err := fillMetadata(&alerts[i], sloDefinition); err != nil {
+			return nil, fieldError(specPath.Child("annotations"), err)
+		}
+		alertRules = append(alertRules, alerts[i].Rule)
 	}
 
 	return alertRules, nil
 }
 
-func fillMetadata(rule *promoperator.Rule, definition *monitoringv1alpha1.Slo) {
+// alertMethod returns the registered AlertMethod of the record
+func alertMethod(record monitoringv1alpha1.ExprBlock, recordPath *field.Path) (AlertMethod, error) {
+	method := GetAlertMethod(record.AlertMethod)
+	if method == nil {
+		return nil, fieldError(recordPath.Child("alertMethod"), fmt.Errorf("%w %q", ErrUnknownAlertMethod, record.AlertMethod))
+	}
+	return method, nil
+}
+
+// parseMinRate returns the traffic guard of the alerts, 0 when the Slo does not
+// record traffic or does not set a minimum rate
+func parseMinRate(trafficRateRecord monitoringv1alpha1.ExprBlock) (float64, error) {
+	if trafficRateRecord.Expr == "" || trafficRateRecord.MinRate == "" {
+		return 0, nil
+	}
+
+	minRate, err := strconv.ParseFloat(trafficRateRecord.MinRate, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert %s to float", trafficRateRecord.MinRate)
+	}
+	return minRate, nil
+}
+
+// parseWindows converts the alerting windows of a record, falling back to
+// DefaultWindows for Slos that were created before the defaulting webhook.
+func parseWindows(recordWindows []monitoringv1alpha1.Window, objectivesWindow string) ([]Window, error) {
+	if len(recordWindows) == 0 {
+		recordWindows = monitoringv1alpha1.DefaultWindowsFor(monitoringv1alpha1.DefaultWindows, objectivesWindow)
+	}
+
+	var windows []Window
+	for _, recordWindow := range recordWindows {
+		recWindowDuration, err := model.ParseDuration(recordWindow.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s to duration", recordWindow.Duration)
+		}
+
+		recWindowConsumption, err := strconv.ParseFloat(recordWindow.Consumption, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s to float", recordWindow.Consumption)
+		}
+
+		windows = append(windows, Window{
+			Duration:     recWindowDuration,
+			Consumption:  recWindowConsumption,
+			Notification: recordWindow.Notification,
+		})
+	}
+
+	return windows, nil
+}
+
+// parseBudgetThresholds converts the thresholds of the error-budget method,
+// falling back to DefaultBudgetThresholds
+func parseBudgetThresholds(recordThresholds []monitoringv1alpha1.BudgetThreshold) ([]BudgetThreshold, error) {
+	if len(recordThresholds) == 0 {
+		recordThresholds = monitoringv1alpha1.DefaultBudgetThresholds
+	}
+
+	var thresholds []BudgetThreshold
+	for _, recordThreshold := range recordThresholds {
+		remaining, err := strconv.ParseFloat(recordThreshold.Remaining, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %s to float", recordThreshold.Remaining)
+		}
+
+		thresholds = append(thresholds, BudgetThreshold{
+			Remaining:    remaining,
+			Notification: recordThreshold.Notification,
+		})
+	}
+
+	return thresholds, nil
+}
+
+// parseForecast converts the configuration of the forecast method, unset
+// fields fall back to DefaultForecast
+func parseForecast(recordForecast *monitoringv1alpha1.Forecast) (Forecast, error) {
+	config := monitoringv1alpha1.DefaultForecast
+	if recordForecast != nil {
+		if recordForecast.Horizon != "" {
+			config.Horizon = recordForecast.Horizon
+		}
+		if recordForecast.Lookback != "" {
+			config.Lookback = recordForecast.Lookback
+		}
+		if recordForecast.Notification != "" {
+			config.Notification = recordForecast.Notification
+		}
+	}
+
+	horizon, err := model.ParseDuration(config.Horizon)
+	if err != nil {
+		return Forecast{}, fmt.Errorf("failed to convert %s to duration", config.Horizon)
+	}
+
+	lookback, err := model.ParseDuration(config.Lookback)
+	if err != nil {
+		return Forecast{}, fmt.Errorf("failed to convert %s to duration", config.Lookback)
+	}
+
+	return Forecast{
+		Horizon:      time.Duration(horizon),
+		Lookback:     time.Duration(lookback),
+		Notification: config.Notification,
+	}, nil
+}
+
+func fillMetadata(alert *Alert, definition *monitoringv1alpha1.Slo) error {
+	rule := &alert.Rule
 	rule.Labels["namespace"] = definition.Namespace
 	for label, value := range definition.Labels {
 		rule.Labels[label] = value
 	}
+	for label, value := range identityLabels(definition) {
+		rule.Labels[label] = value
+	}
 
 	rule.Annotations["namespace"] = definition.Namespace
 
+	annotations, err := renderAnnotations(definition.Spec.Annotations, newAlertTemplateData(alert, definition))
+	if err != nil {
+		return fmt.Errorf("failed to render annotations of alert %s: %w", rule.Alert, err)
+	}
+	for annotation, value := range annotations {
+		rule.Annotations[annotation] = value
+	}
+
+	return nil
 }
 
-func generateGroupRules(slo *monitoringv1alpha1.Slo) ([]promoperator.RuleGroup, error) {
+func generateGroupRules(slo *monitoringv1alpha1.Slo, samples []monitoringv1alpha1.Sample, name string) ([]promoperator.RuleGroup, error) {
 	var rules []promoperator.RuleGroup
 
-	var latencyBuckets []string
-	if len(slo.Spec.LatencyRecord.Buckets) > 0 {
-		for _, bucket := range slo.Spec.LatencyRecord.Buckets {
-			latencyBuckets = append(latencyBuckets, bucket)
-		}
+	latencyBuckets := latencyBuckets(slo)
 
+	budgets, err := errorBudgets(slo, name)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, sample := range monitoringv1alpha1.DefaultSamples {
+	for _, sample := range samples {
 
 		ruleGroup := promoperator.RuleGroup{
 			Name:     fmt.Sprintf("slo:%s:%s", slo.Name, sample.Name),
@@ -231,7 +548,8 @@ func generateGroupRules(slo *monitoringv1alpha1.Slo) ([]promoperator.RuleGroup,
 		}
 
 		for _, bucket := range sample.Buckets {
-			ruleGroup.Rules = append(ruleGroup.Rules, generateRules(bucket, latencyBuckets, slo)...)
+			ruleGroup.Rules = append(ruleGroup.Rules, generateRules(bucket, latencyBuckets, sample.GetRecordQuantiles(), name, slo)...)
+			ruleGroup.Rules = append(ruleGroup.Rules, generateBudgetRules(bucket, budgets, name, slo)...)
 		}
 
 		if len(ruleGroup.Rules) > 0 {
@@ -242,22 +560,34 @@ func generateGroupRules(slo *monitoringv1alpha1.Slo) ([]promoperator.RuleGroup,
 	return rules, nil
 }
 
-func Labelslabels(slo *monitoringv1alpha1.Slo) map[string]string {
-	labels := make(map[string]string)
-	labels["service"] = slo.Name
-	for key, value := range slo.Labels {
-		labels[key] = value
+// latencyBuckets returns the le values to record the latency ratio for, the
+// buckets of the latency record followed by the objective targets so that the
+// latency alerts always select a recorded series
+func latencyBuckets(slo *monitoringv1alpha1.Slo) []string {
+	var buckets []string
+	seen := map[string]bool{}
+	for _, bucket := range slo.Spec.LatencyRecord.Buckets {
+		if !seen[bucket] {
+			seen[bucket] = true
+			buckets = append(buckets, bucket)
+		}
+	}
+	for _, latency := range slo.Spec.Objectives.Latency {
+		if !seen[latency.LE] {
+			seen[latency.LE] = true
+			buckets = append(buckets, latency.LE)
+		}
 	}
-	return labels
+	return buckets
 }
 
-func generateRules(bucket string, latencyBuckets []string, sloDefinition *monitoringv1alpha1.Slo) []promoperator.Rule {
+func generateRules(bucket string, latencyBuckets []string, recordQuantiles bool, name string, sloDefinition *monitoringv1alpha1.Slo) []promoperator.Rule {
 	var rules []promoperator.Rule
 	if sloDefinition.Spec.TrafficRateRecord.Expr != "" {
 		trafficRateRecord := promoperator.Rule{
-			Record: fmt.Sprintf("slo:%s:service_traffic:ratio_rate_%s", santizeString(sloDefinition.Name), bucket),
+			Record: fmt.Sprintf("slo:%s:service_traffic:ratio_rate_%s", name, bucket),
 			Expr:   intstr.IntOrString{Type: intstr.String, StrVal: sloDefinition.Spec.TrafficRateRecord.ComputeExpr(bucket, "")},
-			Labels: sloDefinition.Spec.Labels,
+			Labels: recordLabels(sloDefinition, nil),
 		}
 
 		rules = append(rules, trafficRateRecord)
@@ -265,20 +595,20 @@ func generateRules(bucket string, latencyBuckets []string, sloDefinition *monito
 
 	if sloDefinition.Spec.ErrorRateRecord.Expr != "" {
 		errorRateRecord := promoperator.Rule{
-			Record: fmt.Sprintf("slo:%s:service_errors_total:ratio_rate_%s", santizeString(sloDefinition.Name), bucket),
+			Record: fmt.Sprintf("slo:%s:service_errors_total:ratio_rate_%s", name, bucket),
 			Expr:   intstr.IntOrString{Type: intstr.String, StrVal: sloDefinition.Spec.ErrorRateRecord.ComputeExpr(bucket, "")},
-			Labels: sloDefinition.Spec.Labels,
+			Labels: recordLabels(sloDefinition, nil),
 		}
 
 		rules = append(rules, errorRateRecord)
 	}
 
-	if sloDefinition.Spec.LatencyQuantileRecord.Expr != "" {
-		for _, quantile := range quantiles {
+	if sloDefinition.Spec.LatencyQuantileRecord.Expr != "" && recordQuantiles {
+		for _, quantile := range sloDefinition.Spec.LatencyQuantileRecord.GetQuantiles() {
 			latencyQuantileRecord := promoperator.Rule{
-				Record: fmt.Sprintf("slo:%s:service_latency:%s_%s", santizeString(sloDefinition.Name), quantile.name, bucket),
-				Expr:   intstr.IntOrString{Type: intstr.String, StrVal: sloDefinition.Spec.LatencyQuantileRecord.ComputeQuantile(bucket, quantile.quantile)},
-				Labels: sloDefinition.Spec.Labels,
+				Record: fmt.Sprintf("slo:%s:service_latency:%s_%s", name, monitoringv1alpha1.QuantileName(quantile), bucket),
+				Expr:   intstr.IntOrString{Type: intstr.String, StrVal: sloDefinition.Spec.LatencyQuantileRecord.ComputeQuantile(bucket, quantile)},
+				Labels: recordLabels(sloDefinition, nil),
 			}
 
 			rules = append(rules, latencyQuantileRecord)
@@ -288,13 +618,11 @@ func generateRules(bucket string, latencyBuckets []string, sloDefinition *monito
 	if sloDefinition.Spec.LatencyRecord.Expr != "" {
 		for _, latencyBucket := range latencyBuckets {
 			latencyRateRecord := promoperator.Rule{
-				Record: fmt.Sprintf("slo:%s:service_latency:ratio_rate_%s", santizeString(sloDefinition.Name), bucket),
+				Record: fmt.Sprintf("slo:%s:service_latency:ratio_rate_%s", name, bucket),
 				Expr:   intstr.IntOrString{Type: intstr.String, StrVal: sloDefinition.Spec.LatencyRecord.ComputeExpr(bucket, latencyBucket)},
-				Labels: sloDefinition.Spec.Labels,
+				Labels: recordLabels(sloDefinition, map[string]string{"le": latencyBucket}),
 			}
 
-			latencyRateRecord.Labels["le"] = latencyBucket
-
 			rules = append(rules, latencyRateRecord)
 		}
 	}
@@ -302,8 +630,9 @@ func generateRules(bucket string, latencyBuckets []string, sloDefinition *monito
 	return rules
 }
 
+// santizeString turns a Kubernetes name into a valid part of a metric name
 func santizeString(name string) string {
 
-	return strings.Replace(name, "-", "_", -1)
+	return invalidMetricNameChars.ReplaceAllString(name, "_")
 
 }
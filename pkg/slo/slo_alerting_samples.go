@@ -0,0 +1,95 @@
+package slo
+
+import (
+	"regexp"
+	"sort"
+	"time"
+
+	monitoringv1alpha1 "github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+	promoperator "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/common/model"
+)
+
+// ratioRateWindow matches the window suffix of the recorded ratio series read by alerts
+var ratioRateWindow = regexp.MustCompile(`:ratio_rate_([0-9]+(?:ms|[ywdhms]))\b`)
+
+// referencedWindows returns every ratio_rate window read by the alert rules,
+// shortest first
+func referencedWindows(rules []promoperator.Rule) []string {
+	seen := map[string]bool{}
+	var windows []string
+	for _, rule := range rules {
+		for _, match := range ratioRateWindow.FindAllStringSubmatch(rule.Expr.String(), -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				windows = append(windows, match[1])
+			}
+		}
+	}
+
+	sort.SliceStable(windows, func(i, j int) bool {
+		return windowDuration(windows[i]) < windowDuration(windows[j])
+	})
+	return windows
+}
+
+// alertingSamples returns samples for the windows that are not recorded by any
+// of the given samples. Each window is evaluated at the interval of the first
+// default sample whose longest bucket covers it, so a 20m window is recorded
+// with the short group interval and a 28d window with the daily one.
+func alertingSamples(windows []string, samples []monitoringv1alpha1.Sample) []monitoringv1alpha1.Sample {
+	recorded := map[string]bool{}
+	for _, sample := range samples {
+		for _, bucket := range sample.Buckets {
+			recorded[bucket] = true
+		}
+	}
+
+	var alerting []monitoringv1alpha1.Sample
+	recordQuantiles := false
+	index := map[string]int{}
+	for _, window := range windows {
+		if recorded[window] {
+			continue
+		}
+
+		base := evaluationSample(windowDuration(window))
+		i, ok := index[base.Name]
+		if !ok {
+			i = len(alerting)
+			index[base.Name] = i
+			alerting = append(alerting, monitoringv1alpha1.Sample{
+				Name:     base.Name + ":alerting",
+				Interval: base.Interval,
+				// alerts only read ratio_rate series
+				RecordQuantiles: &recordQuantiles,
+			})
+		}
+		alerting[i].Buckets = append(alerting[i].Buckets, window)
+	}
+
+	return alerting
+}
+
+func evaluationSample(window time.Duration) monitoringv1alpha1.Sample {
+	for _, sample := range monitoringv1alpha1.DefaultSamples {
+		longest := time.Duration(0)
+		for _, bucket := range sample.Buckets {
+			if d := windowDuration(bucket); d > longest {
+				longest = d
+			}
+		}
+		if window <= longest {
+			return sample
+		}
+	}
+	return monitoringv1alpha1.DefaultSamples[len(monitoringv1alpha1.DefaultSamples)-1]
+}
+
+func windowDuration(window string) time.Duration {
+	duration, err := model.ParseDuration(window)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(duration)
+}
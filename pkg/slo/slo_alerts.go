@@ -1,33 +1,61 @@
 package slo
 
 import (
-	promoperator "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"strconv"
 	"time"
+
+	promoperator "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/prometheus/pkg/labels"
 )
 
 type AlertErrorOptions struct {
 	ServiceName        string
+	Selector           labels.Labels
 	AvailabilityTarget string
 	SLOWindow          time.Duration
 
 	Windows     []Window
 	ShortWindow bool
 	BurnRate    string
+	// MinTrafficRate silences windows whose traffic rate is not above it, 0 disables the guard
+	MinTrafficRate float64
+	// BudgetThresholds are used by the error-budget method
+	BudgetThresholds []BudgetThreshold
+	// Forecast is used by the forecast method
+	Forecast Forecast
 }
 
 type AlertLatencyOptions struct {
 	ServiceName string
+	Selector    labels.Labels
 	Targets     []LatencyTarget
 	SLOWindow   time.Duration
 
 	Windows     []Window
 	ShortWindow bool
 	BurnRate    string
+	// MinTrafficRate silences windows whose traffic rate is not above it, 0 disables the guard
+	MinTrafficRate float64
+	// BudgetThresholds are used by the error-budget method
+	BudgetThresholds []BudgetThreshold
+	// Forecast is used by the forecast method
+	Forecast Forecast
+}
+
+// Alert is a generated alerting rule together with the values it was built
+// from, which are made available to the annotation templates
+type Alert struct {
+	Rule     promoperator.Rule
+	SLI      string // errors or latency
+	Severity string
+	Windows  []MultiRateWindow
+	// Remaining is the percentage of the error budget left at which an error-budget alert fires
+	Remaining string
 }
 
 type AlertMethod interface {
-	AlertForError(*AlertErrorOptions) ([]promoperator.Rule, error)
-	AlertForLatency(*AlertLatencyOptions) ([]promoperator.Rule, error)
+	AlertForError(*AlertErrorOptions) ([]Alert, error)
+	AlertForLatency(*AlertLatencyOptions) ([]Alert, error)
 }
 
 var methods = map[string]AlertMethod{}
@@ -41,7 +69,20 @@ func GetAlertMethod(name string) AlertMethod {
 	return methods[name]
 }
 
+// IsAlertMethod reports whether name has been registered as an AlertMethod
+func IsAlertMethod(name string) bool {
+	return GetAlertMethod(name) != nil
+}
+
 type LatencyTarget struct {
 	LE     string
 	Target float64
 }
+
+// formatThreshold prints a threshold of an alert expression. The precision is
+// high enough for targets such as 99.95 to keep their value, 1 - 0.0005 must
+// not be rounded to 1, and low enough to drop float noise such as
+// 0.9995000000000001.
+func formatThreshold(value float64) string {
+	return strconv.FormatFloat(value, 'g', 12, 64)
+}
@@ -0,0 +1,55 @@
+package slo
+
+import (
+	"strings"
+
+	monitoringv1alpha1 "github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+)
+
+func newAlertTemplateData(alert *Alert, definition *monitoringv1alpha1.Slo) monitoringv1alpha1.AnnotationTemplateData {
+	data := monitoringv1alpha1.AnnotationTemplateData{
+		Name:      definition.Name,
+		Namespace: definition.Namespace,
+		SLI:       alert.SLI,
+		Objective: definition.Spec.Objectives.Availability,
+		Latency:   definition.Spec.Objectives.Latency,
+		Severity:  alert.Severity,
+		Remaining: alert.Remaining,
+	}
+	for _, window := range alert.Windows {
+		data.Windows = append(data.Windows, monitoringv1alpha1.AnnotationWindow{
+			Multiplier:  window.Multiplier,
+			LongWindow:  window.LongWindow,
+			ShortWindow: window.ShortWindow,
+		})
+		data.BurnRates = append(data.BurnRates, window.Multiplier)
+	}
+	return data
+}
+
+// renderAnnotations executes every annotation as a text/template. Prometheus
+// templates such as {{ $value }} or {{ $labels.instance }} are kept as they are.
+func renderAnnotations(annotations map[string]string, data monitoringv1alpha1.AnnotationTemplateData) (map[string]string, error) {
+	rendered := make(map[string]string, len(annotations))
+	for name, text := range annotations {
+		value, err := renderAnnotation(name, text, data)
+		if err != nil {
+			return nil, err
+		}
+		rendered[name] = value
+	}
+	return rendered, nil
+}
+
+func renderAnnotation(name, text string, data monitoringv1alpha1.AnnotationTemplateData) (string, error) {
+	tmpl, err := monitoringv1alpha1.ParseAnnotationTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,124 @@
+package slo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	monitoringv1alpha1 "github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+	promoperator "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// errorBudget is an objective of the Slo whose budget is recorded. The error
+// ratio of a window is ErrorRatio with the window substituted, it selects the
+// series of the Slo by its identity labels so that Slos with the same record
+// names do not read each other's series.
+type errorBudget struct {
+	// ErrorRatio is a format string taking the window
+	ErrorRatio string
+	Budget     float64
+	Labels     map[string]string
+}
+
+// errorBudgets returns the error budget of the availability objective and of
+// each latency target, in that order
+func errorBudgets(sloDefinition *monitoringv1alpha1.Slo, name string) ([]errorBudget, error) {
+	var budgets []errorBudget
+
+	if sloDefinition.Spec.ErrorRateRecord.Expr != "" {
+		availability, err := strconv.ParseFloat(sloDefinition.Spec.Objectives.Availability, 64)
+		if err != nil {
+			return nil, fieldError(field.NewPath("spec", "objectives", "availability"), fmt.Errorf("failed to convert %s to float", sloDefinition.Spec.Objectives.Availability))
+		}
+
+		budgets = append(budgets, errorBudget{
+			ErrorRatio: fmt.Sprintf("slo:%s:service_errors_total:ratio_rate_%%s%s", name, formatSelector(identitySelector(sloDefinition))),
+			Budget:     1 - availability/100,
+			Labels:     budgetLabels(sloDefinition, "errors", ""),
+		})
+	}
+
+	if sloDefinition.Spec.LatencyRecord.Expr != "" {
+		for i, latency := range sloDefinition.Spec.Objectives.Latency {
+			target, err := strconv.ParseFloat(latency.Target, 64)
+			if err != nil {
+				return nil, fieldError(field.NewPath("spec", "objectives", "latency").Index(i).Child("target"), fmt.Errorf("failed to convert %s to float", latency.Target))
+			}
+
+			// the latency records are the ratio of requests within the target
+			selector := withLabel(identitySelector(sloDefinition), "le", latency.LE)
+			budgets = append(budgets, errorBudget{
+				ErrorRatio: fmt.Sprintf(`(1 - slo:%s:service_latency:ratio_rate_%%s%s)`, name, formatSelector(selector)),
+				Budget:     1 - target/100,
+				Labels:     budgetLabels(sloDefinition, "latency", latency.LE),
+			})
+		}
+	}
+
+	return budgets, nil
+}
+
+// formatSelector prints lbs as a selector that can be used in the ErrorRatio
+// format string
+func formatSelector(lbs labels.Labels) string {
+	return strings.ReplaceAll(lbs.String(), "%", "%%")
+}
+
+func budgetLabels(sloDefinition *monitoringv1alpha1.Slo, sli, le string) map[string]string {
+	extra := map[string]string{"sli": sli}
+	if le != "" {
+		extra["le"] = le
+	}
+	return recordLabels(sloDefinition, extra)
+}
+
+// budgetWindow returns the objectives window in the form used by record names,
+// or nothing when the Slo has no budget to record
+func budgetWindow(sloDefinition *monitoringv1alpha1.Slo) []string {
+	if sloDefinition.Spec.ErrorRateRecord.Expr == "" && sloDefinition.Spec.LatencyRecord.Expr == "" {
+		return nil
+	}
+
+	window, err := monitoringv1alpha1.ParseDuration(sloDefinition.Spec.Objectives.Window)
+	if err != nil || window <= 0 {
+		return nil
+	}
+	return []string{model.Duration(window).String()}
+}
+
+// generateBudgetRules records the burn rate of each budget over bucket and, when
+// bucket is the objectives window, the consumed and remaining budget
+func generateBudgetRules(bucket string, budgets []errorBudget, name string, sloDefinition *monitoringv1alpha1.Slo) []promoperator.Rule {
+	var rules []promoperator.Rule
+	window := budgetWindow(sloDefinition)
+
+	for _, budget := range budgets {
+		errorRatio := fmt.Sprintf(budget.ErrorRatio, bucket)
+
+		rules = append(rules, promoperator.Rule{
+			Record: fmt.Sprintf("slo:%s:error_budget:burn_rate_%s", name, bucket),
+			Expr:   intstr.IntOrString{Type: intstr.String, StrVal: fmt.Sprintf("%s / %s", errorRatio, formatThreshold(budget.Budget))},
+			Labels: budget.Labels,
+		})
+
+		if len(window) == 0 || window[0] != bucket {
+			continue
+		}
+
+		rules = append(rules, promoperator.Rule{
+			Record: fmt.Sprintf("slo:%s:error_budget:consumed", name),
+			Expr:   intstr.IntOrString{Type: intstr.String, StrVal: fmt.Sprintf("%s / %s", errorRatio, formatThreshold(budget.Budget))},
+			Labels: budget.Labels,
+		}, promoperator.Rule{
+			Record: fmt.Sprintf("slo:%s:error_budget:remaining", name),
+			Expr:   intstr.IntOrString{Type: intstr.String, StrVal: fmt.Sprintf("1 - %s / %s", errorRatio, formatThreshold(budget.Budget))},
+			Labels: budget.Labels,
+		})
+	}
+
+	return rules
+}
@@ -0,0 +1,54 @@
+package slo
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// BurnRateAlgorithm alerts when the ratio over a single window burns the error
+// budget faster than ExprBlock.BurnRate. The short window, 1/12 of the long
+// one, is only checked as well when shortWindow is true.
+type BurnRateAlgorithm struct{}
+
+func (*BurnRateAlgorithm) AlertForError(opts *AlertErrorOptions) ([]Alert, error) {
+	ratesMap, err := genBurnRateWindows(opts.SLOWindow, opts.ShortWindow, opts.Windows, opts.BurnRate)
+	if err != nil {
+		return nil, err
+	}
+	return errorAlerts(opts, ratesMap)
+}
+
+func (*BurnRateAlgorithm) AlertForLatency(opts *AlertLatencyOptions) ([]Alert, error) {
+	ratesMap, err := genBurnRateWindows(opts.SLOWindow, opts.ShortWindow, opts.Windows, opts.BurnRate)
+	if err != nil {
+		return nil, err
+	}
+	return latencyAlerts(opts, ratesMap)
+}
+
+// genBurnRateWindows uses burnRate as the multiplier of every window. Without a
+// burn rate the multiplier is derived from the consumption of the window, as
+// for multi-window alerts.
+func genBurnRateWindows(SLOWindow time.Duration, shortWindow bool, windows []Window, burnRate string) (map[string][]MultiRateWindow, error) {
+	ratesMap := genMultiRateWindows(SLOWindow, shortWindow, windows)
+	if burnRate == "" {
+		return ratesMap, nil
+	}
+
+	multiplier, err := strconv.ParseFloat(burnRate, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s to float", burnRate)
+	}
+
+	for _, rates := range ratesMap {
+		for i := range rates {
+			rates[i].Multiplier = multiplier
+		}
+	}
+	return ratesMap, nil
+}
+
+func init() {
+	register(&BurnRateAlgorithm{}, "burn-rate")
+}
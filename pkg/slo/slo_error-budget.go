@@ -0,0 +1,88 @@
+package slo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	promoperator "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/common/model"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ErrorBudgetAlgorithm alerts when the error budget left over the Slo window
+// drops to one of the BudgetThresholds. The thresholds are cumulative, once the
+// budget is exhausted the alerts of every threshold are firing.
+type ErrorBudgetAlgorithm struct{}
+
+// BudgetThreshold raises an alert with Notification as severity once no more
+// than Remaining percent of the error budget is left
+type BudgetThreshold struct {
+	Remaining    float64
+	Notification string
+}
+
+func (*ErrorBudgetAlgorithm) AlertForError(opts *AlertErrorOptions) ([]Alert, error) {
+	availabilityTarget, err := strconv.ParseFloat(opts.AvailabilityTarget, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s to float", opts.AvailabilityTarget)
+	}
+
+	window := model.Duration(opts.SLOWindow).String()
+	lbs := opts.Selector
+	errorBudget := 1 - availabilityTarget/100
+
+	var alerts []Alert
+	for _, threshold := range opts.BudgetThresholds {
+		consumed := 1 - threshold.Remaining/100
+		expr := fmt.Sprintf(`slo:%s:service_errors_total:ratio_rate_%s%s > (%s * %s)`, opts.ServiceName, window, lbs.String(), formatThreshold(consumed), formatThreshold(errorBudget))
+
+		alerts = append(alerts, budgetAlert(opts.ServiceName, "errors", window, threshold, expr))
+	}
+	return alerts, nil
+}
+
+func (*ErrorBudgetAlgorithm) AlertForLatency(opts *AlertLatencyOptions) ([]Alert, error) {
+	window := model.Duration(opts.SLOWindow).String()
+
+	var alerts []Alert
+	for _, threshold := range opts.BudgetThresholds {
+		consumed := 1 - threshold.Remaining/100
+
+		var conditions []string
+		for _, target := range opts.Targets {
+			lbs := withLabel(opts.Selector, "le", target.LE)
+			value := 1 - consumed*(100-target.Target)/100
+			conditions = append(conditions, fmt.Sprintf(`slo:%s:service_latency:ratio_rate_%s%s < %s`, opts.ServiceName, window, lbs.String(), formatThreshold(value)))
+		}
+
+		alerts = append(alerts, budgetAlert(opts.ServiceName, "latency", window, threshold, strings.Join(conditions, " or ")))
+	}
+	return alerts, nil
+}
+
+func budgetAlert(serviceName, sli, window string, threshold BudgetThreshold, expr string) Alert {
+	return Alert{
+		Rule: promoperator.Rule{
+			Alert: fmt.Sprintf("slo:%s.%s.budget_%g", serviceName, sli, threshold.Remaining),
+			Expr: intstr.IntOrString{
+				Type:   intstr.String,
+				StrVal: expr,
+			},
+			Annotations: map[string]string{
+				"severity": threshold.Notification,
+			},
+			Labels: map[string]string{
+				"severity": threshold.Notification,
+			},
+		},
+		SLI:       sli,
+		Severity:  threshold.Notification,
+		Windows:   []MultiRateWindow{{LongWindow: window}},
+		Remaining: strconv.FormatFloat(threshold.Remaining, 'g', -1, 64),
+	}
+}
+
+func init() {
+	register(&ErrorBudgetAlgorithm{}, "error-budget")
+}
@@ -0,0 +1,35 @@
+package slo
+
+import (
+	"errors"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ErrUnknownAlertMethod is wrapped by the FieldError returned for an
+// alertMethod that is not registered
+var ErrUnknownAlertMethod = errors.New("unknown alert method")
+
+// FieldError is an error in the generation of rules caused by the value of a
+// field of the Slo spec
+type FieldError struct {
+	Field *field.Path
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// fieldError wraps err with the path of the field it comes from, nil stays nil
+func fieldError(path *field.Path, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &FieldError{Field: path, Err: err}
+}
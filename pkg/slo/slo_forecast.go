@@ -0,0 +1,93 @@
+package slo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	promoperator "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/common/model"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ForecastAlgorithm alerts when the error budget left over the Slo window runs
+// out within Horizon at the burn rate of the Lookback window.
+//
+// With a budget b, the error ratio e_W over the Slo window W and e_L over the
+// lookback, the budget left is (b - e_W) and it is spent at e_L / W per unit of
+// time, so it is exhausted within the horizon H when e_L > (b - e_W) * W / H.
+type ForecastAlgorithm struct{}
+
+// Forecast configures the forecast alert method
+type Forecast struct {
+	Horizon      time.Duration
+	Lookback     time.Duration
+	Notification string
+}
+
+func (*ForecastAlgorithm) AlertForError(opts *AlertErrorOptions) ([]Alert, error) {
+	availabilityTarget, err := strconv.ParseFloat(opts.AvailabilityTarget, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s to float", opts.AvailabilityTarget)
+	}
+
+	metric := fmt.Sprintf("slo:%s:service_errors_total", opts.ServiceName)
+	lbs := opts.Selector
+	window := model.Duration(opts.SLOWindow).String()
+	lookback := model.Duration(opts.Forecast.Lookback).String()
+	factor := opts.SLOWindow.Hours() / opts.Forecast.Horizon.Hours()
+
+	expr := fmt.Sprintf(`%s:ratio_rate_%s%s > (%s - %s:ratio_rate_%s%s) * %s`,
+		metric, lookback, lbs.String(), formatThreshold(1-availabilityTarget/100), metric, window, lbs.String(), formatThreshold(factor))
+	expr = newTrafficGuard(opts.ServiceName, opts.Selector, opts.MinTrafficRate).guard(expr, lookback)
+
+	return []Alert{forecastAlert(opts.ServiceName, "errors", lookback, factor, opts.Forecast, expr)}, nil
+}
+
+func (*ForecastAlgorithm) AlertForLatency(opts *AlertLatencyOptions) ([]Alert, error) {
+	metric := fmt.Sprintf("slo:%s:service_latency", opts.ServiceName)
+	window := model.Duration(opts.SLOWindow).String()
+	lookback := model.Duration(opts.Forecast.Lookback).String()
+	factor := opts.SLOWindow.Hours() / opts.Forecast.Horizon.Hours()
+
+	traffic := newTrafficGuard(opts.ServiceName, opts.Selector, opts.MinTrafficRate, "le")
+
+	// the latency records are the ratio of requests within the target, the
+	// error ratio is 1 minus the record
+	var conditions []string
+	for _, target := range opts.Targets {
+		lbs := withLabel(opts.Selector, "le", target.LE)
+		condition := fmt.Sprintf(`(1 - %s:ratio_rate_%s%s) > (%s:ratio_rate_%s%s - %s) * %s`,
+			metric, lookback, lbs.String(), metric, window, lbs.String(), formatThreshold(target.Target/100), formatThreshold(factor))
+		conditions = append(conditions, traffic.guard(condition, lookback))
+	}
+
+	return []Alert{forecastAlert(opts.ServiceName, "latency", lookback, factor, opts.Forecast, strings.Join(conditions, " or "))}, nil
+}
+
+func forecastAlert(serviceName, sli, lookback string, factor float64, forecast Forecast, expr string) Alert {
+	return Alert{
+		Rule: promoperator.Rule{
+			Alert: fmt.Sprintf("slo:%s.%s.forecast", serviceName, sli),
+			Expr: intstr.IntOrString{
+				Type:   intstr.String,
+				StrVal: expr,
+			},
+			Annotations: map[string]string{
+				"severity": forecast.Notification,
+			},
+			Labels: map[string]string{
+				"severity": forecast.Notification,
+			},
+		},
+		SLI:      sli,
+		Severity: forecast.Notification,
+		// the multiplier is the burn rate that spends a full budget within the horizon
+		Windows: []MultiRateWindow{{Multiplier: factor, LongWindow: lookback}},
+	}
+}
+
+func init() {
+	register(&ForecastAlgorithm{}, "forecast")
+}
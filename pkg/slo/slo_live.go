@@ -0,0 +1,117 @@
+package slo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	monitoringv1alpha1 "github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+	"github.com/prometheus/client_golang/api"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+// Querier runs instant queries, it is implemented by the API of the Prometheus
+// HTTP client
+type Querier interface {
+	Query(ctx context.Context, query string, ts time.Time) (model.Value, api.Warnings, error)
+}
+
+// NewQuerier returns a Querier for the Prometheus HTTP API at address
+func NewQuerier(address string) (Querier, error) {
+	client, err := api.NewClient(api.Config{Address: address})
+	if err != nil {
+		return nil, err
+	}
+	return promv1.NewAPI(client), nil
+}
+
+// LiveValues are the current values of the availability records of an Slo.
+// A value is nil when Prometheus has no series for it yet.
+type LiveValues struct {
+	// SLI is the availability over the objectives window, in percent
+	SLI *float64
+	// BudgetRemaining is the part of the error budget left, in percent
+	BudgetRemaining *float64
+	// BurnRates is the burn rate over the first alerting window of each severity
+	BurnRates map[string]float64
+	Time      time.Time
+}
+
+// QueryLiveValues reads the current values of the records generated for the
+// errors of the Slo
+func QueryLiveValues(ctx context.Context, querier Querier, sloDefinition *monitoringv1alpha1.Slo, options Options, ts time.Time) (LiveValues, error) {
+	values := LiveValues{BurnRates: map[string]float64{}, Time: ts}
+	if sloDefinition.Spec.ErrorRateRecord.Expr == "" {
+		return values, nil
+	}
+
+	name := options.RecordName(sloDefinition)
+	selector := labels.NewBuilder(identitySelector(sloDefinition)).Set("sli", "errors").Labels().String()
+
+	if window := budgetWindow(sloDefinition); len(window) > 0 {
+		errorRatio, err := queryScalar(ctx, querier, fmt.Sprintf("slo:%s:service_errors_total:ratio_rate_%s%s", name, window[0], identitySelector(sloDefinition)), ts)
+		if err != nil {
+			return values, err
+		}
+		if errorRatio != nil {
+			sli := (1 - *errorRatio) * 100
+			values.SLI = &sli
+		}
+
+		remaining, err := queryScalar(ctx, querier, fmt.Sprintf("slo:%s:error_budget:remaining%s", name, selector), ts)
+		if err != nil {
+			return values, err
+		}
+		if remaining != nil {
+			percent := *remaining * 100
+			values.BudgetRemaining = &percent
+		}
+	}
+
+	windows, err := parseWindows(sloDefinition.Spec.ErrorRateRecord.Windows, sloDefinition.Spec.Objectives.Window)
+	if err != nil {
+		return values, err
+	}
+	for _, severity := range Severities {
+		for _, window := range windows {
+			if window.Notification != severity {
+				continue
+			}
+			burnRate, err := queryScalar(ctx, querier, fmt.Sprintf("slo:%s:error_budget:burn_rate_%s%s", name, window.Duration, selector), ts)
+			if err != nil {
+				return values, err
+			}
+			if burnRate != nil {
+				values.BurnRates[severity] = *burnRate
+			}
+			break
+		}
+	}
+
+	return values, nil
+}
+
+// queryScalar returns the value of the single series of an instant query, nil
+// when the query has no result
+func queryScalar(ctx context.Context, querier Querier, query string, ts time.Time) (*float64, error) {
+	result, _, err := querier.Query(ctx, query, ts)
+	if err != nil {
+		return nil, fmt.Errorf("query %s: %w", query, err)
+	}
+
+	vector, ok := result.(model.Vector)
+	if !ok {
+		return nil, fmt.Errorf("query %s: unexpected result type %s", query, result.Type())
+	}
+	if len(vector) == 0 {
+		return nil, nil
+	}
+	if len(vector) > 1 {
+		return nil, fmt.Errorf("query %s: expected one series, got %d", query, len(vector))
+	}
+
+	value := float64(vector[0].Value)
+	return &value, nil
+}
@@ -0,0 +1,96 @@
+package slo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePrometheus serves the instant query API, answering the queries in series
+// with a single sample and any other query with an empty vector
+func fakePrometheus(t *testing.T, series map[string]string) (*httptest.Server, *[]string) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/query", r.URL.Path)
+		query := r.FormValue("query")
+		queries = append(queries, query)
+
+		result := []interface{}{}
+		if value, ok := series[query]; ok {
+			result = append(result, map[string]interface{}{
+				"metric": map[string]string{},
+				"value":  []interface{}{1600000000, value},
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "success",
+			"data":   map[string]interface{}{"resultType": "vector", "result": result},
+		}))
+	}))
+	return server, &queries
+}
+
+func TestQueryLiveValues(t *testing.T) {
+	selector := `{service="test-service", slo_name="test-service", slo_namespace="test-ns"}`
+	budgetSelector := `{service="test-service", sli="errors", slo_name="test-service", slo_namespace="test-ns"}`
+	server, queries := fakePrometheus(t, map[string]string{
+		"slo:test_service:service_errors_total:ratio_rate_30d" + selector: "0.0004",
+		"slo:test_service:error_budget:remaining" + budgetSelector:        "0.6",
+		"slo:test_service:error_budget:burn_rate_1h" + budgetSelector:     "2.5",
+		"slo:test_service:error_budget:burn_rate_1d" + budgetSelector:     "0.75",
+	})
+	defer server.Close()
+
+	querier, err := NewQuerier(server.URL)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	values, err := QueryLiveValues(context.Background(), querier, newTestSlo(), Options{}, now)
+	assert.NoError(t, err)
+	assert.Len(t, *queries, 4)
+
+	if assert.NotNil(t, values.SLI) {
+		assert.InDelta(t, 99.96, *values.SLI, 1e-9)
+	}
+	if assert.NotNil(t, values.BudgetRemaining) {
+		assert.InDelta(t, 60, *values.BudgetRemaining, 1e-9)
+	}
+	assert.Equal(t, map[string]float64{"page": 2.5, "ticket": 0.75}, values.BurnRates)
+	assert.Equal(t, now, values.Time)
+}
+
+func TestQueryLiveValuesWithoutSeries(t *testing.T) {
+	server, _ := fakePrometheus(t, nil)
+	defer server.Close()
+
+	querier, err := NewQuerier(server.URL)
+	assert.NoError(t, err)
+
+	values, err := QueryLiveValues(context.Background(), querier, newTestSlo(), Options{}, time.Now())
+	assert.NoError(t, err)
+	assert.Nil(t, values.SLI)
+	assert.Nil(t, values.BudgetRemaining)
+	assert.Empty(t, values.BurnRates)
+}
+
+func TestQueryLiveValuesError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+	}))
+	defer server.Close()
+
+	querier, err := NewQuerier(server.URL)
+	assert.NoError(t, err)
+
+	_, err = QueryLiveValues(context.Background(), querier, newTestSlo(), Options{}, time.Now())
+	assert.Error(t, err)
+}
@@ -0,0 +1,71 @@
+package slo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	monitoringv1alpha1 "github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+	promoperator "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// generateMetadataGroup records the objectives of the Slo as constant series so
+// that dashboards can join on them. Besides the labels below they carry the
+// identity labels of the Slo like every other record:
+//
+//	slo:objective:ratio{slo, namespace, sli[, le]}  the target, 0.999 for 99.9
+//	slo:period:seconds{slo, namespace}              the objectives window
+//	slo:info{slo, namespace, <spec labels>}         always 1
+func generateMetadataGroup(sloDefinition *monitoringv1alpha1.Slo) (promoperator.RuleGroup, error) {
+	group := promoperator.RuleGroup{
+		Name: fmt.Sprintf("slo:%s:metadata", sloDefinition.Name),
+	}
+
+	availability, err := strconv.ParseFloat(sloDefinition.Spec.Objectives.Availability, 64)
+	if err != nil {
+		return group, fieldError(field.NewPath("spec", "objectives", "availability"), fmt.Errorf("failed to convert %s to float", sloDefinition.Spec.Objectives.Availability))
+	}
+	group.Rules = append(group.Rules, constantRule("slo:objective:ratio", availability/100, metadataLabels(sloDefinition, map[string]string{"sli": "errors"})))
+
+	for i, latency := range sloDefinition.Spec.Objectives.Latency {
+		target, err := strconv.ParseFloat(latency.Target, 64)
+		if err != nil {
+			return group, fieldError(field.NewPath("spec", "objectives", "latency").Index(i).Child("target"), fmt.Errorf("failed to convert %s to float", latency.Target))
+		}
+		group.Rules = append(group.Rules, constantRule("slo:objective:ratio", target/100, metadataLabels(sloDefinition, map[string]string{"sli": "latency", "le": latency.LE})))
+	}
+
+	if window, err := monitoringv1alpha1.ParseDuration(sloDefinition.Spec.Objectives.Window); err == nil && window > 0 {
+		group.Rules = append(group.Rules, constantRule("slo:period:seconds", window.Seconds(), metadataLabels(sloDefinition, nil)))
+	}
+
+	group.Rules = append(group.Rules, constantRule("slo:info", 1, metadataLabels(sloDefinition, sloDefinition.Spec.Labels)))
+
+	return group, nil
+}
+
+func metadataLabels(sloDefinition *monitoringv1alpha1.Slo, extra map[string]string) map[string]string {
+	labels := map[string]string{}
+	for key, value := range extra {
+		labels[key] = value
+	}
+	for key, value := range identityLabels(sloDefinition) {
+		labels[key] = value
+	}
+	labels["slo"] = sloDefinition.Name
+	labels["namespace"] = sloDefinition.Namespace
+	return labels
+}
+
+func constantRule(record string, value float64, labels map[string]string) promoperator.Rule {
+	// dividing percentages leaves float noise such as 0.9990000000000001
+	value = math.Round(value*1e10) / 1e10
+
+	return promoperator.Rule{
+		Record: record,
+		Expr:   intstr.IntOrString{Type: intstr.String, StrVal: fmt.Sprintf("vector(%s)", strconv.FormatFloat(value, 'f', -1, 64))},
+		Labels: labels,
+	}
+}
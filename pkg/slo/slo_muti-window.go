@@ -15,17 +15,51 @@ import (
 type MultiWindowAlgorithm struct{}
 
 type MultiRateErrorOpts struct {
-	Rates  []MultiRateWindow
-	Metric string
-	Labels labels.Labels
-	Value  float64
+	Rates   []MultiRateWindow
+	Metric  string
+	Labels  labels.Labels
+	Value   float64
+	Traffic TrafficGuard
 }
 
 type MultiRateLatencyOpts struct {
 	Rates   []MultiRateWindow
 	Metric  string
-	Label   labels.Label
+	Labels  labels.Labels
 	Buckets []LatencyTarget
+	Traffic TrafficGuard
+}
+
+// TrafficGuard requires the traffic rate of a window to be above MinRate for
+// the burn rate of that window to alert. It is disabled when MinRate is 0.
+type TrafficGuard struct {
+	Metric  string
+	Labels  labels.Labels
+	MinRate float64
+	// Ignoring lists the labels of the burn rate series that the traffic series does not have
+	Ignoring []string
+}
+
+func newTrafficGuard(serviceName string, selector labels.Labels, minRate float64, ignoring ...string) TrafficGuard {
+	return TrafficGuard{
+		Metric:   fmt.Sprintf("slo:%s:service_traffic", serviceName),
+		Labels:   selector,
+		MinRate:  minRate,
+		Ignoring: ignoring,
+	}
+}
+
+// guard ANDs condition with the traffic rate of the same window
+func (guard TrafficGuard) guard(condition, window string) string {
+	if guard.MinRate <= 0 {
+		return condition
+	}
+
+	matching := ""
+	if len(guard.Ignoring) > 0 {
+		matching = fmt.Sprintf(" ignoring(%s)", strings.Join(guard.Ignoring, ", "))
+	}
+	return fmt.Sprintf(`(%s and%s %s:ratio_rate_%s%s > %g)`, condition, matching, guard.Metric, window, guard.Labels.String(), guard.MinRate)
 }
 
 type MultiRateWindow struct {
@@ -34,75 +68,63 @@ type MultiRateWindow struct {
 	ShortWindow string
 }
 
-var multiRateWindows = map[string][]MultiRateWindow{
-
-	"page": {
-		{
-			Multiplier:  14.4,
-			LongWindow:  "1h",
-			ShortWindow: "5m",
-		},
-		{
-			Multiplier:  6,
-			LongWindow:  "6h",
-			ShortWindow: "30m",
-		},
-	},
-	"ticket": {
-		{
-			Multiplier:  3,
-			LongWindow:  "1d",
-			ShortWindow: "2h",
-		},
-		{
-			Multiplier:  1,
-			LongWindow:  "3d",
-			ShortWindow: "6h",
-		},
-	},
+func (*MultiWindowAlgorithm) AlertForError(opts *AlertErrorOptions) ([]Alert, error) {
+	return errorAlerts(opts, genMultiRateWindows(opts.SLOWindow, opts.ShortWindow, opts.Windows))
+}
+
+func (*MultiWindowAlgorithm) AlertForLatency(opts *AlertLatencyOptions) ([]Alert, error) {
+	return latencyAlerts(opts, genMultiRateWindows(opts.SLOWindow, opts.ShortWindow, opts.Windows))
 }
 
-func (*MultiWindowAlgorithm) AlertForError(opts *AlertErrorOptions) ([]promoperator.Rule, error) {
-	ratesMap := genMultiRateWindows(opts.SLOWindow, opts.ShortWindow, opts.Windows)
-	var rules []promoperator.Rule
+// errorAlerts builds one alert per severity that fires when the error ratio is
+// above any of the burn rates of that severity
+func errorAlerts(opts *AlertErrorOptions, ratesMap map[string][]MultiRateWindow) ([]Alert, error) {
+	var alerts []Alert
+
+	AvailabilityTarget, err := strconv.ParseFloat(opts.AvailabilityTarget, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %s to float", opts.AvailabilityTarget)
+	}
 
 	for _, severity := range Severities {
 		if _, ok := ratesMap[severity]; !ok {
 			continue
 		}
 
-		AvailabilityTarget, err := strconv.ParseFloat(opts.AvailabilityTarget, 32)
-		if err == nil {
-			fmt.Println(err) // 3.1415927410125732
-		}
-
 		multiBurnRate := multiBurnRate(MultiRateErrorOpts{
-			Rates:  ratesMap[severity],
-			Metric: fmt.Sprintf("slo:%s:service_errors_total", opts.ServiceName),
-			Labels: labels.New(labels.Label{Name: "service", Value: opts.ServiceName}),
-			Value:  1 - AvailabilityTarget/100,
+			Rates:   ratesMap[severity],
+			Metric:  fmt.Sprintf("slo:%s:service_errors_total", opts.ServiceName),
+			Labels:  opts.Selector,
+			Value:   1 - AvailabilityTarget/100,
+			Traffic: newTrafficGuard(opts.ServiceName, opts.Selector, opts.MinTrafficRate),
 		})
 
-		rules = append(rules, promoperator.Rule{
-			Alert: "slo:" + opts.ServiceName + ".errors." + severity,
-			Expr: intstr.IntOrString{
-				Type:   intstr.String,
-				StrVal: multiBurnRate,
-			},
-			Annotations: map[string]string{
-				"severity": severity,
-			},
-			Labels: map[string]string{
-				"severity": severity,
+		alerts = append(alerts, Alert{
+			Rule: promoperator.Rule{
+				Alert: "slo:" + opts.ServiceName + ".errors." + severity,
+				Expr: intstr.IntOrString{
+					Type:   intstr.String,
+					StrVal: multiBurnRate,
+				},
+				Annotations: map[string]string{
+					"severity": severity,
+				},
+				Labels: map[string]string{
+					"severity": severity,
+				},
 			},
+			SLI:      "errors",
+			Severity: severity,
+			Windows:  ratesMap[severity],
 		})
 	}
-	return rules, nil
+	return alerts, nil
 }
 
-func (*MultiWindowAlgorithm) AlertForLatency(opts *AlertLatencyOptions) ([]promoperator.Rule, error) {
-	ratesMap := genMultiRateWindows(opts.SLOWindow, opts.ShortWindow, opts.Windows)
-	var rules []promoperator.Rule
+// latencyAlerts builds one alert per severity that fires when the ratio of
+// requests within any latency target is below its burn rate threshold
+func latencyAlerts(opts *AlertLatencyOptions, ratesMap map[string][]MultiRateWindow) ([]Alert, error) {
+	var alerts []Alert
 
 	for _, severity := range Severities {
 		if _, ok := ratesMap[severity]; !ok {
@@ -111,44 +133,48 @@ func (*MultiWindowAlgorithm) AlertForLatency(opts *AlertLatencyOptions) ([]promo
 		burnRate := multiBurnRateLatency(MultiRateLatencyOpts{
 			Rates:   ratesMap[severity],
 			Metric:  fmt.Sprintf("slo:%s:service_latency", opts.ServiceName),
-			Label:   labels.Label{Name: "service", Value: opts.ServiceName},
+			Labels:  opts.Selector,
 			Buckets: opts.Targets,
+			Traffic: newTrafficGuard(opts.ServiceName, opts.Selector, opts.MinTrafficRate, "le"),
 		})
 
-		rules = append(rules, promoperator.Rule{
-
-			Alert: "slo:" + opts.ServiceName + ".latency." + severity,
-			Expr: intstr.IntOrString{
-				Type: intstr.String,
-
-				StrVal: burnRate,
-			},
-			For: "",
-			Labels: map[string]string{
-				"severity": severity,
-			},
-			Annotations: map[string]string{
-				"severity": severity,
+		alerts = append(alerts, Alert{
+			Rule: promoperator.Rule{
+
+				Alert: "slo:" + opts.ServiceName + ".latency." + severity,
+				Expr: intstr.IntOrString{
+					Type: intstr.String,
+
+					StrVal: burnRate,
+				},
+				For: "",
+				Labels: map[string]string{
+					"severity": severity,
+				},
+				Annotations: map[string]string{
+					"severity": severity,
+				},
 			},
+			SLI:      "latency",
+			Severity: severity,
+			Windows:  ratesMap[severity],
 		})
 	}
 
-	return rules, nil
+	return alerts, nil
 }
 
 func genMultiRateWindows(SLOWindow time.Duration, shortWindow bool, windows []Window) map[string][]MultiRateWindow {
-	if len(windows) == 0 {
-		// Use Default multiRateWindows from SRE Book
-		return multiRateWindows
-	}
-
 	mrate := map[string][]MultiRateWindow{}
-	wHours := float64(SLOWindow / time.Hour)
+	wHours := SLOWindow.Hours()
 
 	for _, w := range windows {
-		t := float64(time.Duration(w.Duration) / time.Hour)
+		t := time.Duration(w.Duration).Hours()
 
 		burnRate := (w.Consumption / 100) / (t / wHours)
+		// consumptions derived from the objectives window, such as 2.142857142857143%
+		// of 28d in 1h, give burn rates like 14.400000000000002
+		burnRate, _ = strconv.ParseFloat(formatThreshold(burnRate), 64)
 		m := MultiRateWindow{
 			Multiplier: burnRate,
 			LongWindow: w.Duration.String(),
@@ -170,9 +196,11 @@ func multiBurnRate(opts MultiRateErrorOpts) string {
 	conditions := []string{}
 
 	for _, window := range multiRateWindow {
-		condition := fmt.Sprintf(`%s:ratio_rate_%s%s > (%g * %.3g)`, opts.Metric, window.LongWindow, opts.Labels.String(), window.Multiplier, opts.Value)
+		condition := fmt.Sprintf(`%s:ratio_rate_%s%s > (%s * %s)`, opts.Metric, window.LongWindow, opts.Labels.String(), formatThreshold(window.Multiplier), formatThreshold(opts.Value))
+		condition = opts.Traffic.guard(condition, window.LongWindow)
 		if window.ShortWindow != "" {
-			condition = fmt.Sprintf(`(%s and %s:ratio_rate_%s%s > (%g * %.3g))`, condition, opts.Metric, window.ShortWindow, opts.Labels.String(), window.Multiplier, opts.Value)
+			short := fmt.Sprintf(`%s:ratio_rate_%s%s > (%s * %s)`, opts.Metric, window.ShortWindow, opts.Labels.String(), formatThreshold(window.Multiplier), formatThreshold(opts.Value))
+			condition = fmt.Sprintf(`(%s and %s)`, condition, opts.Traffic.guard(short, window.ShortWindow))
 		}
 
 		conditions = append(conditions, condition)
@@ -193,11 +221,13 @@ func multiBurnRateLatency(opts MultiRateLatencyOpts) string {
 		for _, window := range multiRateWindow {
 
 			value := (1 - ((100 - bucket.Target) / 100 * window.Multiplier))
-			lbs := labels.New(opts.Label, labels.Label{Name: "le", Value: bucket.LE})
+			lbs := withLabel(opts.Labels, "le", bucket.LE)
 
-			condition := fmt.Sprintf(`%s:ratio_rate_%s%s < %.3g`, opts.Metric, window.LongWindow, lbs.String(), value)
+			condition := fmt.Sprintf(`%s:ratio_rate_%s%s < %s`, opts.Metric, window.LongWindow, lbs.String(), formatThreshold(value))
+			condition = opts.Traffic.guard(condition, window.LongWindow)
 			if window.ShortWindow != "" {
-				condition = fmt.Sprintf(`(%s and %s:ratio_rate_%s%s < %.3g)`, condition, opts.Metric, window.ShortWindow, lbs.String(), value)
+				short := fmt.Sprintf(`%s:ratio_rate_%s%s < %s`, opts.Metric, window.ShortWindow, lbs.String(), formatThreshold(value))
+				condition = fmt.Sprintf(`(%s and %s)`, condition, opts.Traffic.guard(short, window.ShortWindow))
 			}
 
 			conditions = append(conditions, condition)
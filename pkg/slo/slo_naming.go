@@ -0,0 +1,92 @@
+package slo
+
+import (
+	"fmt"
+
+	monitoringv1alpha1 "github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+const (
+	// NamingName prefixes records with the name of the Slo, slo:<name>:...
+	NamingName = "name"
+	// NamingNamespaceName prefixes records with the namespace and the name of
+	// the Slo, slo:<namespace>:<name>:..., so that Slos with the same name in
+	// different namespaces do not share record names
+	NamingNamespaceName = "namespace-name"
+)
+
+// NamingStrategies lists the supported values of Options.Naming
+var NamingStrategies = []string{NamingName, NamingNamespaceName}
+
+// IsNamingStrategy reports whether naming is one of NamingStrategies
+func IsNamingStrategy(naming string) bool {
+	for _, strategy := range NamingStrategies {
+		if naming == strategy {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordName returns the part of the record and alert names that identifies
+// the Slo, according to the naming strategy of the options
+func (options Options) RecordName(sloDefinition *monitoringv1alpha1.Slo) string {
+	if options.Naming == NamingNamespaceName {
+		return fmt.Sprintf("%s:%s", santizeString(sloDefinition.Namespace), santizeString(sloDefinition.Name))
+	}
+	return santizeString(sloDefinition.Name)
+}
+
+const (
+	// LabelSloName and LabelSloNamespace are set on the generated PrometheusRule
+	// so that the operator finds it even where owner references do not apply
+	LabelSloName      = "monitoring.kanzifucius.com/slo-name"
+	LabelSloNamespace = "monitoring.kanzifucius.com/slo-namespace"
+)
+
+// TrackingLabels returns the labels that identify the resources generated for
+// the Slo
+func TrackingLabels(sloDefinition *monitoringv1alpha1.Slo) map[string]string {
+	return map[string]string{
+		LabelSloName:      sloDefinition.Name,
+		LabelSloNamespace: sloDefinition.Namespace,
+	}
+}
+
+// identityLabels are set on every generated record and alert, alert
+// expressions select the records of their Slo with them. They are set through
+// the labels of the rules and override labels of the same name in the spec or
+// in the result of the user's expression.
+func identityLabels(sloDefinition *monitoringv1alpha1.Slo) map[string]string {
+	return map[string]string{
+		"service":       sloDefinition.Name,
+		"slo_name":      sloDefinition.Name,
+		"slo_namespace": sloDefinition.Namespace,
+	}
+}
+
+func identitySelector(sloDefinition *monitoringv1alpha1.Slo) labels.Labels {
+	return labels.FromMap(identityLabels(sloDefinition))
+}
+
+// recordLabels returns a new map with the spec labels, the identity labels
+// and the given extra labels of a record
+func recordLabels(sloDefinition *monitoringv1alpha1.Slo, extra map[string]string) map[string]string {
+	recordLabels := map[string]string{}
+	for key, value := range sloDefinition.Spec.Labels {
+		recordLabels[key] = value
+	}
+	for key, value := range identityLabels(sloDefinition) {
+		recordLabels[key] = value
+	}
+	for key, value := range extra {
+		recordLabels[key] = value
+	}
+	return recordLabels
+}
+
+// withLabel returns a copy of lbs with the label added
+func withLabel(lbs labels.Labels, name, value string) labels.Labels {
+	return labels.NewBuilder(lbs).Set(name, value).Labels()
+}
@@ -0,0 +1,45 @@
+package slo
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// SelectorLabels returns labels that a rule needs to be selected by the
+// ruleSelector of a Prometheus. Selectors that no fixed set of labels can be
+// derived from, such as an Exists requirement, return an error naming the key
+// that has to be set through spec.ruleLabels.
+func SelectorLabels(selector *metav1.LabelSelector, ruleLabels map[string]string) (map[string]string, error) {
+	required := map[string]string{}
+	if selector == nil {
+		return required, nil
+	}
+
+	parsed, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	requirements, _ := parsed.Requirements()
+	for _, requirement := range requirements {
+		if _, ok := ruleLabels[requirement.Key()]; ok {
+			continue
+		}
+		switch requirement.Operator() {
+		case selection.Equals, selection.DoubleEquals, selection.In:
+			values := requirement.Values().List()
+			required[requirement.Key()] = values[0]
+		case selection.NotIn, selection.NotEquals, selection.DoesNotExist:
+		default:
+			return nil, fmt.Errorf("the ruleSelector requires label %s, set it in spec.ruleLabels", requirement.Key())
+		}
+	}
+
+	merged := labels.Merge(required, ruleLabels)
+	if !parsed.Matches(merged) {
+		return nil, fmt.Errorf("the rule labels %s are not selected by the ruleSelector %s", labels.Set(ruleLabels), parsed)
+	}
+	return required, nil
+}
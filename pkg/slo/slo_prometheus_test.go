@@ -0,0 +1,57 @@
+package slo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSelectorLabels(t *testing.T) {
+	selector := &metav1.LabelSelector{
+		MatchLabels: map[string]string{"release": "prom-operator"},
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "role", Operator: metav1.LabelSelectorOpIn, Values: []string{"slo", "alert-rules"}},
+			{Key: "tier", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"test"}},
+		},
+	}
+
+	required, err := SelectorLabels(selector, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"release": "prom-operator", "role": "alert-rules"}, required)
+
+	required, err = SelectorLabels(selector, map[string]string{"role": "slo"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"release": "prom-operator"}, required)
+
+	required, err = SelectorLabels(nil, map[string]string{"role": "slo"})
+	assert.NoError(t, err)
+	assert.Empty(t, required)
+
+	_, err = SelectorLabels(selector, map[string]string{"release": "other"})
+	assert.Error(t, err)
+
+	_, err = SelectorLabels(selector, map[string]string{"tier": "test"})
+	assert.Error(t, err)
+
+	_, err = SelectorLabels(&metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "team", Operator: metav1.LabelSelectorOpExists},
+	}}, nil)
+	assert.EqualError(t, err, "the ruleSelector requires label team, set it in spec.ruleLabels")
+}
+
+func TestRuleLabelsPrecedence(t *testing.T) {
+	sloDefinition := newTestSlo()
+	sloDefinition.Labels = map[string]string{"release": "from-metadata", "app": "api"}
+	sloDefinition.Spec.RuleLabels = map[string]string{"release": "from-spec"}
+
+	rule, err := GeneratePromRules(sloDefinition, Options{
+		DefaultRuleLabels:      map[string]string{"release": "from-operator", "team": "sre"},
+		DefaultRuleAnnotations: map[string]string{"owner": "sre"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "from-spec", rule.Labels["release"])
+	assert.Equal(t, "sre", rule.Labels["team"])
+	assert.Equal(t, "api", rule.Labels["app"])
+	assert.Equal(t, map[string]string{"owner": "sre"}, rule.Annotations)
+}
@@ -0,0 +1,71 @@
+package slo
+
+import (
+	"fmt"
+	"strings"
+
+	promoperator "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/prometheus/promql"
+)
+
+// RuleError is a generated rule whose expression is not valid PromQL
+type RuleError struct {
+	Group string
+	// Rule is the record or alert name of the rule
+	Rule string
+	Expr string
+	Err  error
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("rule %s in group %s: %v", e.Rule, e.Group, e.Err)
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// InvalidRulesError is returned by GeneratePromRules when some of the generated
+// expressions do not parse, typically because of a typo in the Slo expressions
+type InvalidRulesError struct {
+	Rules []*RuleError
+}
+
+func (e *InvalidRulesError) Error() string {
+	messages := make([]string, 0, len(e.Rules))
+	for _, rule := range e.Rules {
+		messages = append(messages, rule.Error())
+	}
+	return fmt.Sprintf("%d generated rules are not valid PromQL: %s", len(e.Rules), strings.Join(messages, "; "))
+}
+
+// validateGroups checks that group names are unique, Prometheus rejects the
+// whole rule file otherwise, and parses the expression of every rule with the
+// Prometheus parser
+func validateGroups(groups []promoperator.RuleGroup) error {
+	names := map[string]bool{}
+	for _, group := range groups {
+		if names[group.Name] {
+			return fmt.Errorf("rule group %s is generated more than once, rename the sample that produces it", group.Name)
+		}
+		names[group.Name] = true
+	}
+
+	var invalid []*RuleError
+	for _, group := range groups {
+		for _, rule := range group.Rules {
+			if _, err := promql.ParseExpr(rule.Expr.String()); err != nil {
+				name := rule.Record
+				if name == "" {
+					name = rule.Alert
+				}
+				invalid = append(invalid, &RuleError{Group: group.Name, Rule: name, Expr: rule.Expr.String(), Err: err})
+			}
+		}
+	}
+
+	if len(invalid) > 0 {
+		return &InvalidRulesError{Rules: invalid}
+	}
+	return nil
+}
@@ -1,10 +1,15 @@
 package slo
 
 import (
+	"errors"
 	monitoringv1alpha1 "github.com/kanzifucius/promethues-operator-slos/api/v1alpha1"
+	promoperator "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	"github.com/stretchr/testify/assert"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSimpleSLOGenerateAlertRules(t *testing.T) {
@@ -51,9 +56,759 @@ func TestSimpleSLOGenerateAlertRules(t *testing.T) {
 		},
 	}
 
-	alertRules, _ := GeneratePromRules(sloDefinition)
+	alertRules, _ := GeneratePromRules(sloDefinition, Options{})
 	assert.NotNil(t, alertRules, "no Prometheus rule generated")
 	assert.NotEmpty(t, alertRules.Spec.Groups, "no groups for Prometheus rules")
-	assert.Equal(t, len(alertRules.Spec.Groups), 4, "generated rules should have 4 groups")
+	// short, medium and daily recording groups, the metadata group and the alert group
+	assert.Equal(t, len(alertRules.Spec.Groups), 5, "generated rules should have 5 groups")
 
 }
+
+func TestDefaultWindowsMatchSREWorkbook(t *testing.T) {
+	windows, err := parseWindows(nil, "30d")
+	assert.NoError(t, err)
+
+	rates := genMultiRateWindows(30*24*time.Hour, true, windows)
+	assert.Equal(t, map[string][]MultiRateWindow{
+		"page": {
+			{Multiplier: 14.4, LongWindow: "1h", ShortWindow: "5m"},
+			{Multiplier: 6, LongWindow: "6h", ShortWindow: "30m"},
+		},
+		"ticket": {
+			{Multiplier: 3, LongWindow: "1d", ShortWindow: "2h"},
+			{Multiplier: 1, LongWindow: "3d", ShortWindow: "6h"},
+		},
+	}, rates)
+}
+
+// The default windows keep the SRE workbook multipliers and their short
+// windows whatever the objectives window
+func TestDefaultWindowsKeepMultipliersOverObjectivesWindow(t *testing.T) {
+	shortWindow := false
+	sloDefinition := newTestSlo()
+	sloDefinition.Spec.Objectives.Window = "28d"
+	sloDefinition.Spec.ErrorRateRecord.ShortWindow = &shortWindow
+	sloDefinition.Default()
+
+	block := sloDefinition.Spec.ErrorRateRecord
+	assert.Equal(t, "2.142857142857143", block.Windows[0].Consumption)
+	windows, err := parseWindows(block.Windows, sloDefinition.Spec.Objectives.Window)
+	assert.NoError(t, err)
+
+	rates := genMultiRateWindows(28*24*time.Hour, block.GetShortWindow(), windows)
+	assert.Equal(t, map[string][]MultiRateWindow{
+		"page": {
+			{Multiplier: 14.4, LongWindow: "1h", ShortWindow: "5m"},
+			{Multiplier: 6, LongWindow: "6h", ShortWindow: "30m"},
+		},
+		"ticket": {
+			{Multiplier: 3, LongWindow: "1d", ShortWindow: "2h"},
+			{Multiplier: 1, LongWindow: "3d", ShortWindow: "6h"},
+		},
+	}, rates)
+
+	// Slos that were not defaulted get the same windows
+	windows, err = parseWindows(nil, "28d")
+	assert.NoError(t, err)
+	assert.Equal(t, rates, genMultiRateWindows(28*24*time.Hour, true, windows))
+
+	rule, err := GeneratePromRules(sloDefinition, Options{})
+	if assert.NoError(t, err) {
+		var exprs []string
+		for _, group := range rule.Spec.Groups {
+			for _, r := range group.Rules {
+				if r.Alert != "" {
+					exprs = append(exprs, r.Expr.String())
+				}
+			}
+		}
+		assert.Contains(t, strings.Join(exprs, "\n"), "ratio_rate_5m{service=\"test-service\", slo_name=\"test-service\", slo_namespace=\"test-ns\"} > (14.4 * 0.001)")
+	}
+}
+
+func newTestSlo() *monitoringv1alpha1.Slo {
+	return &monitoringv1alpha1.Slo{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-service",
+			Namespace: "test-ns",
+		},
+		Spec: monitoringv1alpha1.SloSpec{
+			Objectives: monitoringv1alpha1.Objectives{
+				Availability: "99.9",
+				Window:       "30d",
+			},
+			ErrorRateRecord: monitoringv1alpha1.ExprBlock{
+				AlertMethod: "multi-window",
+				Expr:        "sum(rate(http_requests_total{status=\"5xx\"}[$window])) / sum(rate(http_requests_total[$window]))",
+			},
+		},
+	}
+}
+
+func groupNames(groups []promoperator.RuleGroup) []string {
+	var names []string
+	for _, group := range groups {
+		names = append(names, group.Name)
+	}
+	return names
+}
+
+func TestSamplesPrecedence(t *testing.T) {
+	operatorSamples := []monitoringv1alpha1.Sample{{Name: "operator", Interval: "1m", Buckets: []string{"5m", "1h"}}}
+	sloSamples := []monitoringv1alpha1.Sample{{Name: "monthly", Interval: "10m", Buckets: []string{"28d"}}}
+
+	sloDefinition := newTestSlo()
+	rule, err := GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+	assert.Contains(t, groupNames(rule.Spec.Groups), "slo:test-service:daily")
+
+	rule, err = GeneratePromRules(sloDefinition, Options{DefaultSamples: operatorSamples})
+	assert.NoError(t, err)
+	assert.Contains(t, groupNames(rule.Spec.Groups), "slo:test-service:operator")
+	assert.NotContains(t, groupNames(rule.Spec.Groups), "slo:test-service:daily")
+
+	sloDefinition.Spec.Samples = sloSamples
+	rule, err = GeneratePromRules(sloDefinition, Options{DefaultSamples: operatorSamples})
+	assert.NoError(t, err)
+	assert.Equal(t, "slo:test-service:monthly", rule.Spec.Groups[0].Name)
+	assert.Equal(t, "10m", rule.Spec.Groups[0].Interval)
+	assert.Equal(t, "slo:test_service:service_errors_total:ratio_rate_28d", rule.Spec.Groups[0].Rules[0].Record)
+}
+
+func recordNames(groups []promoperator.RuleGroup) []string {
+	var names []string
+	for _, group := range groups {
+		for _, rule := range group.Rules {
+			if rule.Record != "" {
+				names = append(names, rule.Record)
+			}
+		}
+	}
+	return names
+}
+
+func TestAlertWindowsAreRecorded(t *testing.T) {
+	sloDefinition := newTestSlo()
+	sloDefinition.Spec.ErrorRateRecord.Windows = []monitoringv1alpha1.Window{
+		{Duration: "4h", Consumption: "5", Notification: "page"},
+	}
+
+	rule, err := GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+
+	records := recordNames(rule.Spec.Groups)
+	assert.Contains(t, records, "slo:test_service:service_errors_total:ratio_rate_4h")
+	assert.Contains(t, records, "slo:test_service:service_errors_total:ratio_rate_20m")
+
+	// 20m is evaluated with the short interval and 4h with the medium one
+	for _, group := range rule.Spec.Groups {
+		switch group.Name {
+		case "slo:test-service:short:alerting":
+			assert.Equal(t, "30s", group.Interval)
+		case "slo:test-service:medium:alerting":
+			assert.Equal(t, "2m", group.Interval)
+		}
+	}
+	assert.Contains(t, groupNames(rule.Spec.Groups), "slo:test-service:short:alerting")
+	assert.Contains(t, groupNames(rule.Spec.Groups), "slo:test-service:medium:alerting")
+
+	// the alert windows are recorded already, only the 30d Slo window of the
+	// budget records is added next to the metadata and alert groups
+	sloDefinition.Spec.ErrorRateRecord.Windows = nil
+	rule, err = GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+	assert.Len(t, rule.Spec.Groups, len(monitoringv1alpha1.DefaultSamples)+3)
+	assert.Equal(t, "slo:test-service:daily:alerting", rule.Spec.Groups[len(monitoringv1alpha1.DefaultSamples)].Name)
+}
+
+func TestLatencyQuantiles(t *testing.T) {
+	disabled := false
+	sloDefinition := newTestSlo()
+	sloDefinition.Spec.LatencyQuantileRecord = monitoringv1alpha1.ExprBlock{
+		Expr:      "histogram_quantile($quantile, sum(rate(http_request_duration_seconds_bucket[$window])) by (le))",
+		Quantiles: []string{"0.9", "0.999"},
+	}
+	sloDefinition.Spec.Samples = []monitoringv1alpha1.Sample{
+		{Name: "short", Interval: "30s", Buckets: []string{"5m", "30m", "1h", "6h"}},
+		{Name: "daily", Interval: "5m", Buckets: []string{"1d", "3d"}, RecordQuantiles: &disabled},
+	}
+
+	rule, err := GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+
+	records := recordNames(rule.Spec.Groups)
+	assert.Contains(t, records, "slo:test_service:service_latency:p90_5m")
+	assert.Contains(t, records, "slo:test_service:service_latency:p999_5m")
+	assert.NotContains(t, records, "slo:test_service:service_latency:p50_5m")
+	assert.NotContains(t, records, "slo:test_service:service_latency:p90_1d")
+	assert.Contains(t, records, "slo:test_service:service_errors_total:ratio_rate_1d")
+
+	assert.Equal(t, "histogram_quantile(0.999, sum(rate(http_request_duration_seconds_bucket[5m])) by (le))", rule.Spec.Groups[0].Rules[2].Expr.String())
+}
+
+func TestAlertAnnotationsAreRendered(t *testing.T) {
+	sloDefinition := newTestSlo()
+	sloDefinition.Spec.Annotations = map[string]string{
+		"summary":     "{{ .Name }} in {{ .Namespace }} is burning its {{ .Objective }}% {{ .SLI }} budget",
+		"description": "{{ range $i, $w := .Windows }}{{ $w.LongWindow }}/{{ $w.ShortWindow }} at {{ index $.BurnRates $i }}x; {{ end }}current {{ $value | humanizePercentage }}",
+		"runbook_url": "https://runbooks.example.com/{{ .Severity }}",
+	}
+
+	rule, err := GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+
+	alerts := rule.Spec.Groups[len(rule.Spec.Groups)-1].Rules
+	assert.Len(t, alerts, 2)
+	page := alerts[0].Annotations
+	assert.Equal(t, "test-service in test-ns is burning its 99.9% errors budget", page["summary"])
+	assert.Equal(t, "1h/5m at 14.4x; 6h/30m at 6x; current {{ $value | humanizePercentage }}", page["description"])
+	assert.Equal(t, "https://runbooks.example.com/page", page["runbook_url"])
+	assert.Equal(t, "page", page["severity"])
+	assert.Equal(t, "test-ns", page["namespace"])
+	assert.Equal(t, "https://runbooks.example.com/ticket", alerts[1].Annotations["runbook_url"])
+
+	sloDefinition.Spec.Annotations = map[string]string{"summary": "{{ .Unknown }}"}
+	_, err = GeneratePromRules(sloDefinition, Options{})
+	assert.Error(t, err)
+}
+
+func TestMinTrafficRateGuardsEveryWindow(t *testing.T) {
+	shortWindow := false
+	sloDefinition := newTestSlo()
+	sloDefinition.Spec.TrafficRateRecord = monitoringv1alpha1.ExprBlock{
+		Expr:    "sum(rate(http_requests_total[$window]))",
+		MinRate: "0.5",
+	}
+	sloDefinition.Spec.ErrorRateRecord.ShortWindow = &shortWindow
+	sloDefinition.Spec.ErrorRateRecord.Windows = []monitoringv1alpha1.Window{
+		{Duration: "1h", Consumption: "2", Notification: "page"},
+	}
+
+	rule, err := GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+
+	alerts := rule.Spec.Groups[len(rule.Spec.Groups)-1].Rules
+	assert.Equal(t,
+		`(slo:test_service:service_errors_total:ratio_rate_1h{service="test-service", slo_name="test-service", slo_namespace="test-ns"} > (14.4 * 0.001) and slo:test_service:service_traffic:ratio_rate_1h{service="test-service", slo_name="test-service", slo_namespace="test-ns"} > 0.5)`,
+		alerts[0].Expr.String())
+
+	sloDefinition.Spec.TrafficRateRecord.MinRate = ""
+	rule, err = GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+	alerts = rule.Spec.Groups[len(rule.Spec.Groups)-1].Rules
+	assert.NotContains(t, alerts[0].Expr.String(), "service_traffic")
+}
+
+func TestBurnRateAlertMethod(t *testing.T) {
+	sloDefinition := newTestSlo()
+	sloDefinition.Spec.ErrorRateRecord.AlertMethod = "burn-rate"
+	sloDefinition.Spec.ErrorRateRecord.BurnRate = "10"
+	sloDefinition.Spec.ErrorRateRecord.Windows = []monitoringv1alpha1.Window{
+		{Duration: "2h", Consumption: "2", Notification: "page"},
+	}
+
+	rule, err := GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+
+	alerts := rule.Spec.Groups[len(rule.Spec.Groups)-1].Rules
+	assert.Len(t, alerts, 1)
+	assert.Equal(t, "slo:test_service.errors.page", alerts[0].Alert)
+	assert.Equal(t,
+		`slo:test_service:service_errors_total:ratio_rate_2h{service="test-service", slo_name="test-service", slo_namespace="test-ns"} > (10 * 0.001)`,
+		alerts[0].Expr.String())
+	assert.NotContains(t, recordNames(rule.Spec.Groups), "slo:test_service:service_errors_total:ratio_rate_10m")
+
+	// the short window is opt-in
+	shortWindow := true
+	sloDefinition.Spec.ErrorRateRecord.ShortWindow = &shortWindow
+	rule, err = GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+	alerts = rule.Spec.Groups[len(rule.Spec.Groups)-1].Rules
+	assert.Equal(t,
+		`(slo:test_service:service_errors_total:ratio_rate_2h{service="test-service", slo_name="test-service", slo_namespace="test-ns"} > (10 * 0.001) and slo:test_service:service_errors_total:ratio_rate_10m{service="test-service", slo_name="test-service", slo_namespace="test-ns"} > (10 * 0.001))`,
+		alerts[0].Expr.String())
+	sloDefinition.Spec.ErrorRateRecord.ShortWindow = nil
+
+	// without a burn rate the multiplier follows from the consumption
+	sloDefinition.Spec.ErrorRateRecord.BurnRate = ""
+	rule, err = GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+	alerts = rule.Spec.Groups[len(rule.Spec.Groups)-1].Rules
+	assert.Contains(t, alerts[0].Expr.String(), "> (7.2 * 0.001)")
+}
+
+func TestBurnRateAlertMethodWithHighTargets(t *testing.T) {
+	sloDefinition := newTestSlo()
+	sloDefinition.Spec.Objectives.Availability = "99.95"
+	sloDefinition.Spec.Objectives.Latency = []monitoringv1alpha1.LatencyTarget{{LE: "0.5", Target: "99.95"}}
+	sloDefinition.Spec.ErrorRateRecord.AlertMethod = "burn-rate"
+	sloDefinition.Spec.ErrorRateRecord.BurnRate = "1"
+	sloDefinition.Spec.LatencyRecord = monitoringv1alpha1.ExprBlock{
+		AlertMethod: "burn-rate",
+		BurnRate:    "1",
+		Expr:        "sum(rate(http_request_duration_seconds_bucket{le=\"$le\"}[$window])) / sum(rate(http_requests_total[$window]))",
+	}
+	sloDefinition.Default()
+
+	rule, err := GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+
+	alerts := map[string]string{}
+	for _, r := range rule.Spec.Groups[len(rule.Spec.Groups)-1].Rules {
+		alerts[r.Alert] = r.Expr.String()
+	}
+	selector := `{service="test-service", slo_name="test-service", slo_namespace="test-ns"}`
+	latencySelector := `{le="0.5", service="test-service", slo_name="test-service", slo_namespace="test-ns"}`
+	assert.Equal(t, `slo:test_service:service_errors_total:ratio_rate_1h`+selector+` > (1 * 0.0005)`, alerts["slo:test_service.errors.page"])
+	// rounded to 1 the alert would fire on the first slow request
+	assert.Equal(t, `slo:test_service:service_latency:ratio_rate_1h`+latencySelector+` < 0.9995`, alerts["slo:test_service.latency.page"])
+}
+
+func TestErrorBudgetAlertMethod(t *testing.T) {
+	sloDefinition := newTestSlo()
+	sloDefinition.Spec.ErrorRateRecord.AlertMethod = "error-budget"
+	sloDefinition.Spec.ErrorRateRecord.Windows = nil
+
+	rule, err := GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+
+	alerts := rule.Spec.Groups[len(rule.Spec.Groups)-1].Rules
+	if assert.Len(t, alerts, len(monitoringv1alpha1.DefaultBudgetThresholds)) {
+		assert.Equal(t, "slo:test_service.errors.budget_50", alerts[0].Alert)
+		assert.Equal(t, "ticket", alerts[0].Labels["severity"])
+		assert.Equal(t, `slo:test_service:service_errors_total:ratio_rate_30d{service="test-service", slo_name="test-service", slo_namespace="test-ns"} > (0.5 * 0.001)`, alerts[0].Expr.String())
+		assert.Equal(t, "slo:test_service.errors.budget_0", alerts[2].Alert)
+		assert.Equal(t, "page", alerts[2].Labels["severity"])
+		assert.Equal(t, `slo:test_service:service_errors_total:ratio_rate_30d{service="test-service", slo_name="test-service", slo_namespace="test-ns"} > (1 * 0.001)`, alerts[2].Expr.String())
+	}
+
+	// the Slo window is recorded so that the alerts have a series to read
+	assert.Contains(t, recordNames(rule.Spec.Groups), "slo:test_service:service_errors_total:ratio_rate_30d")
+}
+
+func TestErrorBudgetAlertMethodWithHighTargets(t *testing.T) {
+	sloDefinition := newTestSlo()
+	sloDefinition.Spec.Objectives.Availability = "99.95"
+	sloDefinition.Spec.Objectives.Latency = []monitoringv1alpha1.LatencyTarget{{LE: "0.5", Target: "99.95"}}
+	sloDefinition.Spec.ErrorRateRecord.AlertMethod = "error-budget"
+	sloDefinition.Spec.ErrorRateRecord.Windows = nil
+	sloDefinition.Spec.LatencyRecord = monitoringv1alpha1.ExprBlock{
+		AlertMethod: "error-budget",
+		Expr:        "sum(rate(http_request_duration_seconds_bucket{le=\"$le\"}[$window])) / sum(rate(http_requests_total[$window]))",
+	}
+
+	rule, err := GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+
+	alerts := map[string]string{}
+	for _, r := range rule.Spec.Groups[len(rule.Spec.Groups)-1].Rules {
+		alerts[r.Alert] = r.Expr.String()
+	}
+	selector := `{service="test-service", slo_name="test-service", slo_namespace="test-ns"}`
+	latencySelector := `{le="0.5", service="test-service", slo_name="test-service", slo_namespace="test-ns"}`
+	assert.Equal(t, `slo:test_service:service_errors_total:ratio_rate_30d`+selector+` > (0.5 * 0.0005)`, alerts["slo:test_service.errors.budget_50"])
+	assert.Equal(t, `slo:test_service:service_errors_total:ratio_rate_30d`+selector+` > (1 * 0.0005)`, alerts["slo:test_service.errors.budget_0"])
+	// none of the thresholds may be rounded to 1, the alerts would fire on the first slow request
+	assert.Equal(t, `slo:test_service:service_latency:ratio_rate_30d`+latencySelector+` < 0.99975`, alerts["slo:test_service.latency.budget_50"])
+	assert.Equal(t, `slo:test_service:service_latency:ratio_rate_30d`+latencySelector+` < 0.999625`, alerts["slo:test_service.latency.budget_25"])
+	assert.Equal(t, `slo:test_service:service_latency:ratio_rate_30d`+latencySelector+` < 0.9995`, alerts["slo:test_service.latency.budget_0"])
+}
+
+func TestForecastAlertMethod(t *testing.T) {
+	sloDefinition := newTestSlo()
+	sloDefinition.Spec.ErrorRateRecord.AlertMethod = "forecast"
+
+	rule, err := GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+
+	alerts := rule.Spec.Groups[len(rule.Spec.Groups)-1].Rules
+	if assert.Len(t, alerts, 1) {
+		assert.Equal(t, "slo:test_service.errors.forecast", alerts[0].Alert)
+		assert.Equal(t, "ticket", alerts[0].Labels["severity"])
+		// a 30d budget is spent within 3d at 10 times the sustainable burn rate
+		assert.Equal(t,
+			`slo:test_service:service_errors_total:ratio_rate_6h{service="test-service", slo_name="test-service", slo_namespace="test-ns"} > (0.001 - slo:test_service:service_errors_total:ratio_rate_30d{service="test-service", slo_name="test-service", slo_namespace="test-ns"}) * 10`,
+			alerts[0].Expr.String())
+	}
+
+	sloDefinition.Spec.ErrorRateRecord.Forecast = &monitoringv1alpha1.Forecast{Horizon: "7d", Lookback: "1d", Notification: "page"}
+	rule, err = GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+
+	alerts = rule.Spec.Groups[len(rule.Spec.Groups)-1].Rules
+	assert.Equal(t, "page", alerts[0].Labels["severity"])
+	assert.Contains(t, alerts[0].Expr.String(), "ratio_rate_1d")
+	assert.Contains(t, alerts[0].Expr.String(), "* 4.28571428571")
+}
+
+func TestForecastAlertMethodWithHighTargets(t *testing.T) {
+	sloDefinition := newTestSlo()
+	sloDefinition.Spec.Objectives.Availability = "99.95"
+	sloDefinition.Spec.Objectives.Latency = []monitoringv1alpha1.LatencyTarget{{LE: "0.5", Target: "99.95"}}
+	sloDefinition.Spec.ErrorRateRecord.AlertMethod = "forecast"
+	sloDefinition.Spec.LatencyRecord = monitoringv1alpha1.ExprBlock{
+		AlertMethod: "forecast",
+		Expr:        "sum(rate(http_request_duration_seconds_bucket{le=\"$le\"}[$window])) / sum(rate(http_requests_total[$window]))",
+	}
+
+	rule, err := GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+
+	alerts := map[string]string{}
+	for _, r := range rule.Spec.Groups[len(rule.Spec.Groups)-1].Rules {
+		alerts[r.Alert] = r.Expr.String()
+	}
+	selector := `{service="test-service", slo_name="test-service", slo_namespace="test-ns"}`
+	latencySelector := `{le="0.5", service="test-service", slo_name="test-service", slo_namespace="test-ns"}`
+	assert.Equal(t,
+		`slo:test_service:service_errors_total:ratio_rate_6h`+selector+` > (0.0005 - slo:test_service:service_errors_total:ratio_rate_30d`+selector+`) * 10`,
+		alerts["slo:test_service.errors.forecast"])
+	// a target rounded to 1 would make the right side negative and fire on any slow request
+	assert.Equal(t,
+		`(1 - slo:test_service:service_latency:ratio_rate_6h`+latencySelector+`) > (slo:test_service:service_latency:ratio_rate_30d`+latencySelector+` - 0.9995) * 10`,
+		alerts["slo:test_service.latency.forecast"])
+}
+
+func TestErrorBudgetRecords(t *testing.T) {
+	sloDefinition := newTestSlo()
+	sloDefinition.Spec.Labels = map[string]string{"team": "test-team"}
+	sloDefinition.Spec.Objectives.Latency = []monitoringv1alpha1.LatencyTarget{{LE: "0.5", Target: "99"}}
+	sloDefinition.Spec.LatencyRecord = monitoringv1alpha1.ExprBlock{
+		Expr:    "sum(rate(http_request_duration_seconds_bucket{le=\"$le\"}[$window])) / sum(rate(http_requests_total[$window]))",
+		Buckets: []string{"0.5"},
+	}
+
+	rule, err := GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+
+	rules := map[string][]promoperator.Rule{}
+	for _, group := range rule.Spec.Groups {
+		for _, r := range group.Rules {
+			rules[r.Record] = append(rules[r.Record], r)
+		}
+	}
+
+	burnRate := rules["slo:test_service:error_budget:burn_rate_1h"]
+	if assert.Len(t, burnRate, 2) {
+		assert.Equal(t, `slo:test_service:service_errors_total:ratio_rate_1h{service="test-service", slo_name="test-service", slo_namespace="test-ns"} / 0.001`, burnRate[0].Expr.String())
+		assert.Equal(t, map[string]string{"service": "test-service", "sli": "errors", "slo_name": "test-service", "slo_namespace": "test-ns", "team": "test-team"}, burnRate[0].Labels)
+		assert.Equal(t, `(1 - slo:test_service:service_latency:ratio_rate_1h{le="0.5", service="test-service", slo_name="test-service", slo_namespace="test-ns"}) / 0.01`, burnRate[1].Expr.String())
+		assert.Equal(t, map[string]string{"sli": "latency", "le": "0.5", "service": "test-service", "slo_name": "test-service", "slo_namespace": "test-ns", "team": "test-team"}, burnRate[1].Labels)
+	}
+
+	consumed := rules["slo:test_service:error_budget:consumed"]
+	if assert.Len(t, consumed, 2) {
+		assert.Equal(t, `slo:test_service:service_errors_total:ratio_rate_30d{service="test-service", slo_name="test-service", slo_namespace="test-ns"} / 0.001`, consumed[0].Expr.String())
+	}
+	remaining := rules["slo:test_service:error_budget:remaining"]
+	if assert.Len(t, remaining, 2) {
+		assert.Equal(t, `1 - (1 - slo:test_service:service_latency:ratio_rate_30d{le="0.5", service="test-service", slo_name="test-service", slo_namespace="test-ns"}) / 0.01`, remaining[1].Expr.String())
+	}
+}
+
+// The records divide by the same budget as the error-budget alerts compare to
+func TestErrorBudgetRecordsWithPreciseTargets(t *testing.T) {
+	sloDefinition := newTestSlo()
+	sloDefinition.Spec.Objectives.Availability = "97.1234"
+	sloDefinition.Spec.ErrorRateRecord.AlertMethod = "error-budget"
+	sloDefinition.Spec.ErrorRateRecord.Windows = nil
+
+	rule, err := GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+
+	records := map[string]string{}
+	alerts := map[string]string{}
+	for _, group := range rule.Spec.Groups {
+		for _, r := range group.Rules {
+			if r.Record != "" {
+				records[r.Record] = r.Expr.String()
+			} else {
+				alerts[r.Alert] = r.Expr.String()
+			}
+		}
+	}
+	selector := `{service="test-service", slo_name="test-service", slo_namespace="test-ns"}`
+	assert.Equal(t, `slo:test_service:service_errors_total:ratio_rate_1h`+selector+` / 0.028766`, records["slo:test_service:error_budget:burn_rate_1h"])
+	assert.Equal(t, `slo:test_service:service_errors_total:ratio_rate_30d`+selector+` / 0.028766`, records["slo:test_service:error_budget:consumed"])
+	assert.Equal(t, `1 - slo:test_service:service_errors_total:ratio_rate_30d`+selector+` / 0.028766`, records["slo:test_service:error_budget:remaining"])
+	assert.Equal(t, `slo:test_service:service_errors_total:ratio_rate_30d`+selector+` > (1 * 0.028766)`, alerts["slo:test_service.errors.budget_0"])
+}
+
+// Slos with the same name in different namespaces have the same record names
+// with the default naming, each must only read its own series
+func TestErrorBudgetRecordsOfSameNamedSlos(t *testing.T) {
+	for _, namespace := range []string{"team-a", "team-b"} {
+		sloDefinition := newTestSlo()
+		sloDefinition.Name = "api"
+		sloDefinition.Namespace = namespace
+		sloDefinition.Spec.Objectives.Latency = []monitoringv1alpha1.LatencyTarget{{LE: "0.5", Target: "99"}}
+		sloDefinition.Spec.LatencyRecord = monitoringv1alpha1.ExprBlock{
+			Expr: "sum(rate(http_request_duration_seconds_bucket{le=\"$le\"}[$window])) / sum(rate(http_requests_total[$window]))",
+		}
+
+		rule, err := GeneratePromRules(sloDefinition, Options{})
+		assert.NoError(t, err)
+
+		budgetRecords := 0
+		for _, group := range rule.Spec.Groups {
+			for _, r := range group.Rules {
+				if !strings.HasPrefix(r.Record, "slo:api:error_budget:") {
+					continue
+				}
+				budgetRecords++
+				expr := r.Expr.String()
+				assert.Contains(t, expr, `slo_name="api", slo_namespace="`+namespace+`"`, r.Record)
+				assert.Equal(t, strings.Count(expr, "slo:api:"), strings.Count(expr, `slo_namespace="`+namespace+`"`), r.Record)
+			}
+		}
+		assert.NotZero(t, budgetRecords)
+	}
+}
+
+func TestMetadataRecords(t *testing.T) {
+	sloDefinition := newTestSlo()
+	sloDefinition.Spec.Labels = map[string]string{"team": "test-team"}
+	sloDefinition.Spec.Objectives.Latency = []monitoringv1alpha1.LatencyTarget{{LE: "0.5", Target: "99"}}
+
+	rule, err := GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+
+	var metadata promoperator.RuleGroup
+	for _, group := range rule.Spec.Groups {
+		if group.Name == "slo:test-service:metadata" {
+			metadata = group
+		}
+	}
+
+	if assert.Len(t, metadata.Rules, 4) {
+		assert.Equal(t, "slo:objective:ratio", metadata.Rules[0].Record)
+		assert.Equal(t, "vector(0.999)", metadata.Rules[0].Expr.String())
+		assert.Equal(t, map[string]string{"service": "test-service", "slo_name": "test-service", "slo_namespace": "test-ns", "slo": "test-service", "namespace": "test-ns", "sli": "errors"}, metadata.Rules[0].Labels)
+		assert.Equal(t, "vector(0.99)", metadata.Rules[1].Expr.String())
+		assert.Equal(t, map[string]string{"service": "test-service", "slo_name": "test-service", "slo_namespace": "test-ns", "slo": "test-service", "namespace": "test-ns", "sli": "latency", "le": "0.5"}, metadata.Rules[1].Labels)
+		assert.Equal(t, "slo:period:seconds", metadata.Rules[2].Record)
+		assert.Equal(t, "vector(2592000)", metadata.Rules[2].Expr.String())
+		assert.Equal(t, "slo:info", metadata.Rules[3].Record)
+		assert.Equal(t, map[string]string{"service": "test-service", "slo_name": "test-service", "slo_namespace": "test-ns", "slo": "test-service", "namespace": "test-ns", "team": "test-team"}, metadata.Rules[3].Labels)
+	}
+}
+
+func TestIdentityLabelsAndNaming(t *testing.T) {
+	teamA := newTestSlo()
+	teamA.Name, teamA.Namespace = "api", "team-a"
+	teamB := newTestSlo()
+	teamB.Name, teamB.Namespace = "api", "team-b"
+
+	assert.Equal(t, Options{}.RecordName(teamA), Options{}.RecordName(teamB))
+	assert.Equal(t, "team_a:api", Options{Naming: NamingNamespaceName}.RecordName(teamA))
+	assert.NotEqual(t, Options{Naming: NamingNamespaceName}.RecordName(teamA), Options{Naming: NamingNamespaceName}.RecordName(teamB))
+
+	rule, err := GeneratePromRules(teamA, Options{Naming: NamingNamespaceName})
+	assert.NoError(t, err)
+
+	assert.Contains(t, recordNames(rule.Spec.Groups), "slo:team_a:api:service_errors_total:ratio_rate_5m")
+	for _, group := range rule.Spec.Groups {
+		for _, r := range group.Rules {
+			if r.Record != "" {
+				assert.Equal(t, "api", r.Labels["slo_name"], r.Record)
+				assert.Equal(t, "team-a", r.Labels["slo_namespace"], r.Record)
+			}
+		}
+	}
+
+	alerts := rule.Spec.Groups[len(rule.Spec.Groups)-1].Rules
+	assert.Equal(t, "slo:team_a:api.errors.page", alerts[0].Alert)
+	assert.Equal(t, "team-a", alerts[0].Labels["slo_namespace"])
+	assert.Contains(t, alerts[0].Expr.String(), `slo:team_a:api:service_errors_total:ratio_rate_1h{service="api", slo_name="api", slo_namespace="team-a"}`)
+}
+
+var (
+	selectorRegexp = regexp.MustCompile(`(slo:[a-zA-Z0-9_:]+)\{([^}]*)\}`)
+	matcherRegexp  = regexp.MustCompile(`([a-zA-Z_][a-zA-Z0-9_]*)="([^"]*)"`)
+)
+
+func TestAlertSelectorsMatchRecords(t *testing.T) {
+	for _, method := range []string{"multi-window", "burn-rate", "error-budget", "forecast"} {
+		sloDefinition := newTestSlo()
+		sloDefinition.Spec.Labels = map[string]string{"team": "test-team", "service": "overridden"}
+		sloDefinition.Spec.ErrorRateRecord.AlertMethod = method
+		sloDefinition.Spec.ErrorRateRecord.Forecast = &monitoringv1alpha1.Forecast{}
+		sloDefinition.Spec.TrafficRateRecord = monitoringv1alpha1.ExprBlock{Expr: "sum(rate(http_requests_total[$window]))", MinRate: "1"}
+		sloDefinition.Spec.Objectives.Latency = []monitoringv1alpha1.LatencyTarget{{LE: "0.5", Target: "99"}}
+		sloDefinition.Spec.LatencyRecord = monitoringv1alpha1.ExprBlock{
+			AlertMethod: method,
+			Expr:        "sum(rate(http_request_duration_seconds_bucket{le=\"$le\"}[$window])) / sum(rate(http_requests_total[$window]))",
+		}
+		sloDefinition.Default()
+
+		rule, err := GeneratePromRules(sloDefinition, Options{})
+		assert.NoError(t, err, method)
+
+		records := map[string][]map[string]string{}
+		for _, group := range rule.Spec.Groups {
+			for _, r := range group.Rules {
+				if r.Record != "" {
+					records[r.Record] = append(records[r.Record], r.Labels)
+				}
+			}
+		}
+
+		alerts := rule.Spec.Groups[len(rule.Spec.Groups)-1].Rules
+		assert.NotEmpty(t, alerts, method)
+		for _, alert := range alerts {
+			selectors := selectorRegexp.FindAllStringSubmatch(alert.Expr.String(), -1)
+			assert.NotEmpty(t, selectors, alert.Alert)
+			for _, selector := range selectors {
+				matchers := map[string]string{}
+				for _, matcher := range matcherRegexp.FindAllStringSubmatch(selector[2], -1) {
+					matchers[matcher[1]] = matcher[2]
+				}
+				assert.Equal(t, "test-service", matchers["service"], selector[0])
+				assert.True(t, matchesRecord(records[selector[1]], matchers), "%s: %s selects no record", alert.Alert, selector[0])
+			}
+		}
+	}
+}
+
+func matchesRecord(records []map[string]string, matchers map[string]string) bool {
+	for _, recordLabels := range records {
+		matches := true
+		for name, value := range matchers {
+			if recordLabels[name] != value {
+				matches = false
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+func TestInvalidExpressionsAreRejected(t *testing.T) {
+	sloDefinition := newTestSlo()
+	sloDefinition.Spec.ErrorRateRecord.Expr = "sum(rate(http_requests_total{status=\"5xx\"}[$window])) / sum(rate(http_requests_total[$window])"
+
+	rule, err := GeneratePromRules(sloDefinition, Options{})
+	assert.Nil(t, rule)
+
+	var invalid *InvalidRulesError
+	if assert.True(t, errors.As(err, &invalid), "%v", err) {
+		assert.NotEmpty(t, invalid.Rules)
+		assert.Equal(t, "slo:test-service:short", invalid.Rules[0].Group)
+		assert.Equal(t, "slo:test_service:service_errors_total:ratio_rate_5m", invalid.Rules[0].Rule)
+		assert.Contains(t, invalid.Rules[0].Expr, "[5m]")
+		assert.Error(t, errors.Unwrap(invalid.Rules[0]))
+	}
+}
+
+func TestDuplicateGroupsAreRejected(t *testing.T) {
+	sloDefinition := newTestSlo()
+	sloDefinition.Spec.Samples = []monitoringv1alpha1.Sample{{Name: "metadata", Interval: "1m", Buckets: []string{"5m"}}}
+	_, err := GeneratePromRules(sloDefinition, Options{})
+	assert.Error(t, err, "a sample named like a generated group is rejected")
+
+	err = validateGroups([]promoperator.RuleGroup{{Name: "slo:api:metadata"}, {Name: "slo:api:short"}, {Name: "slo:api:metadata"}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "slo:api:metadata")
+	}
+}
+
+func TestGenerationErrorsCarryTheFieldPath(t *testing.T) {
+	sloDefinition := newTestSlo()
+	sloDefinition.Spec.ErrorRateRecord.AlertMethod = "unknown"
+
+	var fieldErr *FieldError
+	_, err := GeneratePromRules(sloDefinition, Options{})
+	assert.True(t, errors.Is(err, ErrUnknownAlertMethod), "%v", err)
+	if assert.True(t, errors.As(err, &fieldErr)) {
+		assert.Equal(t, "spec.errorRateRecord.alertMethod", fieldErr.Field.String())
+	}
+
+	sloDefinition = newTestSlo()
+	sloDefinition.Spec.LatencyRecord = monitoringv1alpha1.ExprBlock{AlertMethod: "multi-window", Expr: "sum(rate(http_request_duration_seconds_bucket{le=\"$le\"}[$window]))"}
+	sloDefinition.Spec.Objectives.Latency = []monitoringv1alpha1.LatencyTarget{{LE: "0.5", Target: "high"}}
+
+	_, err = GeneratePromRules(sloDefinition, Options{})
+	if assert.True(t, errors.As(err, &fieldErr), "%v", err) {
+		assert.Equal(t, "spec.objectives.latency[0].target", fieldErr.Field.String())
+	}
+}
+
+func TestCompareGroups(t *testing.T) {
+	previous, err := GeneratePromRules(newTestSlo(), Options{})
+	assert.NoError(t, err)
+
+	sloDefinition := newTestSlo()
+	sloDefinition.Spec.Objectives.Availability = "99.5"
+	sloDefinition.Spec.Samples = []monitoringv1alpha1.Sample{{Name: "monthly", Interval: "10m", Buckets: []string{"28d"}}}
+	current, err := GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+
+	assert.True(t, CompareGroups(previous.Spec.Groups, previous.Spec.Groups).Empty())
+
+	changes := CompareGroups(previous.Spec.Groups, current.Spec.Groups)
+	assert.Contains(t, changes.Added, "slo:test-service:monthly")
+	assert.Contains(t, changes.Removed, "slo:test-service:short")
+	assert.Contains(t, changes.Changed, "slo:test-service:metadata")
+	assert.Contains(t, changes.String(), "added slo:test-service:monthly")
+}
+
+func TestRuleCarriesTrackingLabels(t *testing.T) {
+	sloDefinition := newTestSlo()
+	sloDefinition.Labels = map[string]string{"release": "prom-operator"}
+
+	rule, err := GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"release":         "prom-operator",
+		LabelSloName:      "test-service",
+		LabelSloNamespace: "test-ns",
+	}, rule.Labels)
+	assert.Equal(t, map[string]string{"release": "prom-operator"}, sloDefinition.Labels)
+}
+
+func TestRuleNamespace(t *testing.T) {
+	sloDefinition := newTestSlo()
+
+	rule, err := GeneratePromRules(sloDefinition, Options{})
+	assert.NoError(t, err)
+	assert.Equal(t, "test-ns", rule.Namespace)
+	assert.Equal(t, "test-service", rule.Name)
+
+	rule, err = GeneratePromRules(sloDefinition, Options{DefaultRuleNamespace: "monitoring"})
+	assert.NoError(t, err)
+	assert.Equal(t, "monitoring", rule.Namespace)
+	assert.Equal(t, "test-ns-test-service", rule.Name)
+	assert.Equal(t, "test-ns", rule.Labels[LabelSloNamespace])
+
+	sloDefinition.Spec.RuleNamespace = "team-monitoring"
+	rule, err = GeneratePromRules(sloDefinition, Options{DefaultRuleNamespace: "monitoring"})
+	assert.NoError(t, err)
+	assert.Equal(t, "team-monitoring", rule.Namespace)
+
+	sloDefinition.Spec.RuleNamespace = "test-ns"
+	assert.Equal(t, "test-service", Options{DefaultRuleNamespace: "monitoring"}.RuleName(sloDefinition))
+}
+
+func TestRuleNamespaceAllowed(t *testing.T) {
+	options := Options{}
+	assert.True(t, options.RuleNamespaceAllowed("test-ns", "test-ns"))
+	assert.False(t, options.RuleNamespaceAllowed("test-ns", "monitoring"))
+
+	options = Options{DefaultRuleNamespace: "monitoring", AllowedRuleNamespaces: []string{"team-monitoring"}}
+	assert.True(t, options.RuleNamespaceAllowed("test-ns", "test-ns"))
+	assert.True(t, options.RuleNamespaceAllowed("test-ns", "monitoring"))
+	assert.True(t, options.RuleNamespaceAllowed("test-ns", "team-monitoring"))
+	assert.False(t, options.RuleNamespaceAllowed("test-ns", "kube-system"))
+	assert.False(t, options.RuleNamespaceAllowed("test-ns", "other-team"))
+}
+
+func TestRuleTypeMetaCanBeApplied(t *testing.T) {
+	rule, err := GeneratePromRules(newTestSlo(), Options{})
+	assert.NoError(t, err)
+	// server-side apply needs the group version and kind of the object
+	assert.Equal(t, "monitoring.coreos.com/v1", rule.APIVersion)
+	assert.Equal(t, promoperator.PrometheusRuleKind, rule.Kind)
+	assert.Empty(t, rule.ResourceVersion)
+}